@@ -0,0 +1,110 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/pterm/pterm"
+	"gopkg.in/ini.v1"
+)
+
+/*
+
+Sensitive commands write an audit record of who did what to which instance,
+as a JSON line in ~/.ridectl/audit.log. Secret values are never recorded,
+only the keys which were touched.
+
+Records can also be sent to other sinks, set in the [audit] section of
+~/.ridectl/ridectl.cfg:
+
+  [audit]
+  webhook = https://audit.example.com/ridectl
+  kubernetes = event
+
+webhook POSTs each record as JSON. kubernetes is event, to create an Event in
+the target namespace, or annotation, to record the last action in an
+annotation on the namespace.
+
+*/
+
+// Record is an audit log entry.
+type Record struct {
+	Time      time.Time         `json:"time"`
+	User      string            `json:"user"`
+	Host      string            `json:"host,omitempty"`
+	Command   string            `json:"command"`
+	Instance  string            `json:"instance,omitempty"`
+	Namespace string            `json:"namespace,omitempty"`
+	Context   string            `json:"context,omitempty"`
+	Keys      []string          `json:"keys,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// Sink is somewhere audit records are written.
+type Sink interface {
+	Write(ctx context.Context, record Record) error
+}
+
+// Config is the [audit] section of ridectl.cfg.
+type Config struct {
+	Webhook    string
+	Kubernetes string
+}
+
+// LoadConfig reads the [audit] section of ridectl.cfg. A missing file or
+// section has no extra sinks.
+func LoadConfig(ridectlConfigFile string) Config {
+	cfg, err := ini.LooseLoad(ridectlConfigFile)
+	if err != nil {
+		pterm.Warning.Printf("error reading %s: %v\n", ridectlConfigFile, err)
+		return Config{}
+	}
+	section := cfg.Section("audit")
+	return Config{
+		Webhook:    section.Key("webhook").String(),
+		Kubernetes: section.Key("kubernetes").String(),
+	}
+}
+
+// Log fills in the time, user and host of a record and writes it to every
+// sink. Failing sinks are warned about, but never stop the command.
+func Log(record Record, sinks ...Sink) {
+	record.Time = time.Now().UTC()
+	record.User = currentUser()
+	record.Host, _ = os.Hostname()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, sink := range sinks {
+		err := sink.Write(ctx, record)
+		if err != nil {
+			pterm.Warning.Printf("error writing audit record: %v\n", err)
+		}
+	}
+}
+
+func currentUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return os.Getenv("USER")
+	}
+	return u.Username
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotation on the target namespace holding the last audit record.
+const lastActionAnnotation = "ridectl.ridecell.io/last-action"
+
+type fileSink struct {
+	path string
+}
+
+// NewFileSink returns a sink appending JSON lines to a local file.
+func NewFileSink(path string) Sink {
+	return &fileSink{path: path}
+}
+
+func (s *fileSink) Write(_ context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "error encoding audit record")
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "error opening %s", s.path)
+	}
+	defer func() { _ = f.Close() }()
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return errors.Wrapf(err, "error writing %s", s.path)
+	}
+	return nil
+}
+
+type webhookSink struct {
+	url string
+}
+
+// NewWebhookSink returns a sink POSTing each record as JSON.
+func NewWebhookSink(url string) Sink {
+	return &webhookSink{url: url}
+}
+
+func (s *webhookSink) Write(ctx context.Context, record Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "error encoding audit record")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "error creating audit webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending audit webhook")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("audit webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+type eventSink struct {
+	client client.Client
+}
+
+// NewEventSink returns a sink creating an Event in the target namespace.
+func NewEventSink(c client.Client) Sink {
+	return &eventSink{client: c}
+}
+
+func (s *eventSink) Write(ctx context.Context, record Record) error {
+	if record.Namespace == "" {
+		return nil
+	}
+	message := fmt.Sprintf("%s ran ridectl %s on %s", record.User, record.Command, record.Instance)
+	if len(record.Keys) > 0 {
+		message += ", keys: " + strings.Join(record.Keys, ", ")
+	}
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "ridectl-audit-",
+			Namespace:    record.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+			Name:       record.Namespace,
+		},
+		Reason:         "RidectlAudit",
+		Message:        message,
+		Type:           corev1.EventTypeNormal,
+		Source:         corev1.EventSource{Component: "ridectl", Host: record.Host},
+		FirstTimestamp: metav1.NewTime(record.Time),
+		LastTimestamp:  metav1.NewTime(record.Time),
+		Count:          1,
+	}
+	err := s.client.Create(ctx, event)
+	if err != nil {
+		return errors.Wrapf(err, "error creating audit event in %s", record.Namespace)
+	}
+	return nil
+}
+
+type annotationSink struct {
+	client client.Client
+}
+
+// NewAnnotationSink returns a sink recording the last record in an
+// annotation on the target namespace.
+func NewAnnotationSink(c client.Client) Sink {
+	return &annotationSink{client: c}
+}
+
+func (s *annotationSink) Write(ctx context.Context, record Record) error {
+	if record.Namespace == "" {
+		return nil
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "error encoding audit record")
+	}
+	namespace := &corev1.Namespace{}
+	err = s.client.Get(ctx, client.ObjectKey{Name: record.Namespace}, namespace)
+	if err != nil {
+		return errors.Wrapf(err, "error getting namespace %s", record.Namespace)
+	}
+	patch := client.MergeFrom(namespace.DeepCopy())
+	if namespace.Annotations == nil {
+		namespace.Annotations = map[string]string{}
+	}
+	namespace.Annotations[lastActionAnnotation] = string(value)
+	err = s.client.Patch(ctx, namespace, patch)
+	if err != nil {
+		return errors.Wrapf(err, "error annotating namespace %s", record.Namespace)
+	}
+	return nil
+}
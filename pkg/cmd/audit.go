@@ -0,0 +1,61 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/Ridecell/ridectl/pkg/audit"
+	"github.com/Ridecell/ridectl/pkg/cmd/edit"
+	"github.com/pterm/pterm"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// auditLog writes a record of a sensitive action to ~/.ridectl/audit.log, and
+// the sinks set in ridectl.cfg. kubeClient is the target cluster, if any.
+func auditLog(record audit.Record, kubeClient client.Client) {
+	sinks := []audit.Sink{audit.NewFileSink(filepath.Join(ridectlHomeDir, "audit.log"))}
+	cfg := audit.LoadConfig(ridectlConfigFile)
+	if cfg.Webhook != "" {
+		sinks = append(sinks, audit.NewWebhookSink(cfg.Webhook))
+	}
+	if kubeClient != nil {
+		switch cfg.Kubernetes {
+		case "":
+		case "event":
+			sinks = append(sinks, audit.NewEventSink(kubeClient))
+		case "annotation":
+			sinks = append(sinks, audit.NewAnnotationSink(kubeClient))
+		default:
+			pterm.Warning.Printf("unknown audit kubernetes sink %s in %s, expected event or annotation\n", cfg.Kubernetes, ridectlConfigFile)
+		}
+	}
+	audit.Log(record, sinks...)
+}
+
+// auditKeys lists the secret keys touched in a manifest diff, as
+// <namespace>/<name>/<key>.
+func auditKeys(diffs []edit.ObjectDiff) []string {
+	keys := []string{}
+	for _, diff := range diffs {
+		for _, key := range diff.Keys {
+			keys = append(keys, fmt.Sprintf("%s/%s/%s", diff.Namespace, diff.Name, key.Key))
+		}
+	}
+	return keys
+}
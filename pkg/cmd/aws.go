@@ -25,7 +25,6 @@ import (
 	"github.com/Ridecell/ridectl/pkg/utils"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	"github.com/pterm/pterm"
 )
@@ -51,23 +50,15 @@ func getAWSConfig(roleName, region string) (aws.Config, error) {
 		updateAWSAccountInfo = true
 		pterm.Info.Println("If you don't know what to do, refer FAQs: https://docs.google.com/document/d/1v6lbH4NgN6rHBHpELWrcQ4CyqwVeSgeP/preview")
 
-		prompt := promptui.Prompt{
-			Label:    "Enter AWS SSO Start url",
-			Validate: validateStartUrl,
-		}
 		var err error
-		startUrl, err = prompt.Run()
+		startUrl, err = promptInput("Enter AWS SSO Start url", validateStartUrl, "AWS SSO start url", "")
 		if err != nil {
-			return cfg, errors.Wrapf(err, "Prompt failed")
+			return cfg, err
 		}
 
-		prompt = promptui.Prompt{
-			Label:    "Enter AWS Account ID",
-			Validate: validateAccountId,
-		}
-		accountId, err = prompt.Run()
+		accountId, err = promptInput("Enter AWS Account ID", validateAccountId, "AWS account ID", "")
 		if err != nil {
-			return cfg, errors.Wrapf(err, "Prompt failed")
+			return cfg, err
 		}
 	}
 
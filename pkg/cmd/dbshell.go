@@ -19,9 +19,9 @@ import (
 	"os"
 	"strings"
 
+	"github.com/Ridecell/ridectl/pkg/audit"
 	"github.com/Ridecell/ridectl/pkg/exec"
-	"github.com/manifoldco/promptui"
-	"github.com/pkg/errors"
+	"github.com/Ridecell/ridectl/pkg/kubernetes"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/types"
@@ -34,6 +34,12 @@ func init() {
 	rootCmd.AddCommand(dbShellCmd)
 }
 
+var dbShellModeFlag string
+
+func init() {
+	dbShellCmd.Flags().StringVar(&dbShellModeFlag, "mode", "", "(optional) DB login mode: read-only or read-write")
+}
+
 var dbShellCmd = &cobra.Command{
 	Use:   "dbshell [flags] <cluster_name>",
 	Short: "Open a database shell on a Summon instance or microservice",
@@ -64,14 +70,9 @@ var dbShellCmd = &cobra.Command{
 
 		modeTypes := []string{"read-only", "read-write"}
 
-		modePrompt := promptui.Select{
-			Label: "Select DB login mode",
-			Items: modeTypes,
-		}
-
-		_, mode, err := modePrompt.Run()
+		mode, err := promptSelect("Select DB login mode", modeTypes, "--mode", dbShellModeFlag)
 		if err != nil {
-			return errors.Wrapf(err, "Prompt failed")
+			return err
 		}
 
 		secretObj := &corev1.Secret{}
@@ -83,7 +84,7 @@ var dbShellCmd = &cobra.Command{
 				return fmt.Errorf("error getting secret for instance %s", err)
 			}
 
-			clusterName := strings.TrimPrefix(kubeObj.Context, "teleport.aws-us-support.ridecell.io-")
+			clusterName := kubernetes.Clusters.ClusterName(kubeObj.Context)
 			clusterPrefix := strings.Split(clusterName, ".")[0]
 			// Derive RDS instance name using hostname and clusterPrefix
 			// We are adding Cluster prefix to RDS instance names, because
@@ -98,6 +99,7 @@ var dbShellCmd = &cobra.Command{
 			if err != nil {
 				return fmt.Errorf("could not login to database, %s", err)
 			}
+			auditLog(audit.Record{Command: "dbshell", Instance: args[0], Namespace: target.Namespace, Context: kubeObj.Context, Details: map[string]string{"mode": mode}}, kubeObj.Client)
 			pterm.Info.Println("Logging in into database with read-only mode")
 			dbConnectCmd := []string{"db", "connect", rdsInstanceName}
 			return exec.ExecuteCommand("tsh", dbConnectCmd, true)
@@ -111,16 +113,16 @@ var dbShellCmd = &cobra.Command{
 
 			// Prompt user for confirming read-write mode for Prod/UAT env.
 			if target.Env == "prod" || target.Env == "uat" {
-				confirmPrompt := promptui.Prompt{
-					Label:     "This is " + target.Env + " environment. Make sure you really want to use read-write mode",
-					IsConfirm: true,
+				goAhead, err := promptConfirm("This is " + target.Env + " environment. Make sure you really want to use read-write mode")
+				if err != nil {
+					return err
 				}
-				goAhead, _ := confirmPrompt.Run()
-				if goAhead != "y" {
+				if !goAhead {
 					os.Exit(0)
 				}
 			}
 
+			auditLog(audit.Record{Command: "dbshell", Instance: args[0], Namespace: target.Namespace, Context: kubeObj.Context, Details: map[string]string{"mode": mode}}, kubeObj.Client)
 			pterm.Warning.Println("Logging in into database with read-write mode")
 			// Since RDS is only accesible from kuberntes cluster, executing psql command from a pod in cluster.
 			kubectlArgs := []string{"exec", "-it", "-n", "ridectl", "ridectl-helper-0", "--context", kubeObj.Context, "--", "env", "PGPASSWORD=" + string(secretObj.Data["password"]), "psql", "-h", string(secretObj.Data["host"]), "-U", string(secretObj.Data["username"]), string(secretObj.Data["dbname"])}
@@ -17,42 +17,48 @@ limitations under the License.
 package cmd
 
 import (
-	"bytes"
-	"encoding/base64"
-	"encoding/gob"
 	"os"
 	"strings"
 
 	"github.com/Ridecell/ridectl/pkg/cmd/edit"
-	"github.com/aws/aws-sdk-go-v2/service/kms"
 
 	"github.com/pkg/errors"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
-	"golang.org/x/crypto/nacl/secretbox"
-)
-
-var (
-	keyMap = map[string]*[32]byte{}
 )
 
 func init() {
 	rootCmd.AddCommand(decryptCmd)
 }
 
+func init() {
+	decryptCmd.Flags().StringVar(&keyProviderFlag, "key-provider", "", keyProviderUsage)
+	decryptCmd.Flags().BoolVar(&stdoutFlag, "stdout", false, "(optional) Write to stdout instead of the file name without .encrypted")
+	// Shadows the global -o output format, which decrypt doesn't use.
+	decryptCmd.Flags().StringVar(&outputFileFlag, "output", "", "(optional) Path to write to instead of the file name without .encrypted")
+	decryptCmd.Flags().BoolVarP(&recursiveFlag, "recursive", "R", false, "(optional) Decrypt the .encrypted files in directories, limited to those listed in their "+encryptListFile+" or matching --include")
+	decryptCmd.Flags().StringSliceVar(&includeFlag, "include", nil, "(optional) Glob of files to decrypt with -R, can be repeated")
+	decryptCmd.Flags().StringSliceVar(&excludeFlag, "exclude", nil, "(optional) Glob of files to skip with -R, can be repeated")
+}
+
 /*
 
 An explanation of the overall decrypt process:
 
-1. The existing file is loaded
+1. The existing file is loaded, or stdin for -
 2. Then its decoded using encoding/gob library
-3. The cipher data key is extracted from decoded file data, and then using KMS decrypt, we obtain plainData key from cipher data key
-4. Then, encrypted file data is decrypted using plain data key, and written to file.
+3. The cipher data key is extracted from decoded file data, and then using KMS decrypt, we obtain plainData key from cipher data key.
+   Files are decrypted in parallel, and each data key is only decrypted once.
+4. Then, encrypted file data is decrypted using plain data key, and written to file, or stdout for - and --stdout.
+
+With -R, the arguments are directories and every .encrypted file in them is
+decrypted, limited to those selected by .ridectl-encrypt and --include like
+encrypt -R, when given.
 
 */
 
 var decryptCmd = &cobra.Command{
-	Use:   "decrypt <file-names>",
+	Use:   "decrypt [--stdout | --output <path>] <file-names | -> | -R <dirs>",
 	Short: "Decrypt files",
 	Long:  `decrypt files that has secret values`,
 	Args: func(_ *cobra.Command, args []string) error {
@@ -63,78 +69,124 @@ var decryptCmd = &cobra.Command{
 		return nil
 	},
 	RunE: func(_ *cobra.Command, fileNames []string) error {
-
-		cfg, err := getAWSConfig("kms-grants", "us-west-1")
+		toStdout, err := streamOutput(fileNames)
 		if err != nil {
-			return errors.Wrapf(err, "error creating AWS session")
+			return err
+		}
+		if recursiveFlag {
+			fileNames, err = findFilesToEncrypt(fileNames, true)
+			if err != nil {
+				return err
+			}
+			if len(fileNames) == 0 {
+				pterm.Info.Println("No files to decrypt")
+				return nil
+			}
 		}
 
-		// Create an Amazon KMS service client
-		kmsService := kms.NewFromConfig(cfg)
+		keyProvider, err := getKeyProvider(keySettingsFile(fileNames[0]))
+		if err != nil {
+			return err
+		}
 
-		for _, filename := range fileNames {
+		// Decrypt every file first, in parallel
+		plaintexts := make([][]byte, len(fileNames))
+		err = edit.ForEachParallel(len(fileNames), func(i int) error {
 			// read file content
-			fileContent, err := os.ReadFile(filename)
+			fileContent, err := readInput(fileNames[i])
 			if err != nil {
-				return errors.Wrapf(err, "error reading file: %s", filename)
+				return errors.Wrapf(err, "error reading file: %s", fileNames[i])
 			}
 
-			// call function here
-			plaintext, err := GetDecryptedData(kmsService, fileContent)
+			plaintexts[i], err = GetDecryptedData(keyProvider, fileContent)
 			if err != nil {
-				return errors.Wrapf(err, "filename: %s", filename)
+				return errors.Wrapf(err, "filename: %s", fileNames[i])
 			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for i, filename := range fileNames {
+			plaintext := plaintexts[i]
 
 			// output file name
 			out_filename := strings.TrimSuffix(filename, ".encrypted")
+			if outputFileFlag != "" {
+				out_filename = outputFileFlag
+			}
 
 			// Check if out_filename exists and has same decrypted data
 			// If true, don't need to write file
-			decryptedFileContent, err := os.ReadFile(out_filename)
-			if err == nil {
-				if string(decryptedFileContent) == string(plaintext) {
-					pterm.Info.Println("No changes: " + out_filename)
-					continue
+			if !toStdout {
+				decryptedFileContent, err := os.ReadFile(out_filename)
+				if err == nil {
+					if string(decryptedFileContent) == string(plaintext) {
+						pterm.Info.Println("No changes: " + out_filename)
+						err = matchModTime(out_filename, filename)
+						if err != nil {
+							return err
+						}
+						continue
+					}
 				}
 			}
 
 			// write decrypted content in <filename>.decrypted
-			err = os.WriteFile(out_filename, plaintext, 0644)
+			err = writeOutput(out_filename, toStdout, plaintext)
 			if err != nil {
 				return errors.Wrapf(err, "error writing file: %s", filename)
 			}
-			pterm.Success.Println("Decrypted : " + out_filename)
+			if !toStdout {
+				pterm.Success.Println("Decrypted : " + out_filename)
+				err = matchModTime(out_filename, filename)
+				if err != nil {
+					return err
+				}
+			}
 		}
 
 		return nil
 	},
 }
 
-func GetDecryptedData(kmsService *kms.Client, encryptedData []byte) ([]byte, error) {
-	var p edit.Payload
+// matchModTime sets the modification time of a decrypted file to that of the
+// file it was decrypted from, so encrypt --check doesn't take it as newer.
+func matchModTime(filename string, encFilename string) error {
+	if encFilename == "-" || !isNewer(filename, encFilename) {
+		return nil
+	}
+	encInfo, err := os.Stat(encFilename)
+	if err != nil {
+		return errors.Wrapf(err, "error reading file: %s", encFilename)
+	}
+	err = os.Chtimes(filename, encInfo.ModTime(), encInfo.ModTime())
+	if err != nil {
+		return errors.Wrapf(err, "error updating file: %s", filename)
+	}
+	return nil
+}
+
+func GetDecryptedData(keyProvider edit.KeyProvider, encryptedData []byte) ([]byte, error) {
 	var plaintext []byte
 
-	decodedData := make([]byte, base64.StdEncoding.DecodedLen(len(encryptedData)))
-	_, err := base64.StdEncoding.Decode(decodedData, encryptedData)
+	// Either envelope format, v1 files are just the base64 payload.
+	p, algorithm, err := edit.DecodePayload(strings.TrimSpace(string(encryptedData)))
 	if err != nil {
-		return plaintext, errors.Wrap(err, "error base64 decoding value")
+		return plaintext, err
 	}
 
-	_ = gob.NewDecoder(bytes.NewReader(decodedData)).Decode(&p)
-	plainDataKey, ok := keyMap[string(p.Key)]
-	if !ok {
-		// Decrypt cipherdatakey
-		plainDataKey, _, err = edit.DecryptCipherDataKey(kmsService, p.Key)
-		if err != nil {
-			return plaintext, errors.Wrap(err, "error decrypting value for cipherDatakey")
-		}
-		keyMap[string(p.Key)] = plainDataKey
+	// Decrypt cipherdatakey, cached across files
+	plainDataKey, _, err := edit.DecryptCipherDataKey(keyProvider, p.Key)
+	if err != nil {
+		return plaintext, errors.Wrap(err, "error decrypting value for cipherDatakey")
 	}
 
 	// Decrypt file content
-	plaintext, ok = secretbox.Open(plaintext, p.Message, p.Nonce, plainDataKey)
-	if !ok {
-		return plaintext, errors.New("Error decrypting value with data key")
+	plaintext, err = edit.OpenPayload(p, algorithm, plainDataKey, nil)
+	if err != nil {
+		return plaintext, errors.Wrap(err, "Error decrypting value with data key")
 	}
 	return plaintext, nil
 }
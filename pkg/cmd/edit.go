@@ -18,20 +18,27 @@ package cmd
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
 
+	"github.com/Ridecell/ridectl/pkg/audit"
 	"github.com/Ridecell/ridectl/pkg/cmd/edit"
-	"github.com/aws/aws-sdk-go-v2/service/kms"
-	"github.com/manifoldco/promptui"
+	"github.com/Ridecell/ridectl/pkg/kubernetes"
 	"github.com/pkg/errors"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+	"gopkg.in/ini.v1"
 )
 
 func init() {
@@ -40,12 +47,26 @@ func init() {
 
 var filenameFlag string
 var keyIdFlag string
+var editDiffFlag bool
+var showValuesFlag bool
+var dryRunFlag bool
+var regionFlag string
+var slackChannelFlag string
 
 var whitespaceRegexp *regexp.Regexp
 
 func init() {
 	editCmd.Flags().StringVarP(&filenameFlag, "file", "f", "", "(optional) Path to the file to edit")
 	editCmd.Flags().StringVarP(&keyIdFlag, "key", "k", "", "(optional) KMS key ID to use for encrypting")
+	editCmd.Flags().StringVar(&keyProviderFlag, "key-provider", "", keyProviderUsage)
+	editCmd.Flags().StringVar(&envelopeFlag, "envelope", edit.EnvelopeV1, envelopeUsage)
+	editCmd.Flags().BoolVar(&strictFlag, "strict", false, strictUsage)
+	editCmd.Flags().BoolVarP(&recrypt, "recrypt", "r", false, "(optional) Re-encrypt all values, e.g. to move them to the v2 envelope")
+	editCmd.Flags().BoolVar(&editDiffFlag, "diff", true, "(optional) Show the changed keys and confirm before writing the file")
+	editCmd.Flags().BoolVar(&showValuesFlag, "show-values", false, "(optional) Show the plaintext values in the diff instead of masking them")
+	editCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "(optional) Show the diff without writing the file")
+	editCmd.Flags().StringVar(&regionFlag, "region", "", "(optional) Region of a new instance (eu, us, in, etc.)")
+	editCmd.Flags().StringVar(&slackChannelFlag, "slack-channel", "", "(optional) Slack channel (#channel-name) a new instance alerts to")
 
 	whitespaceRegexp = regexp.MustCompile(`\s+`)
 }
@@ -55,13 +76,14 @@ func init() {
 An explanation of the overall edit process:
 
 1. The existing file is loaded and parsed.
-2. That parsed data is decrypted using KMS.
+2. That parsed data is decrypted using KMS, or the configured key provider.
 3. A new YAML document is written to a tempfile with the decrypted data.
 4. The tempfile is opened in $EDITOR.
 5. The tempfile is re-read and parsed.
 6. The old and new data is correlated to match up any objects that exist in both.
-7. The parsed data is encrypted using KMS if the value changed.
-8. A new YAML document is written to the original file.
+7. The changed keys are shown and the user confirms the changes.
+8. The parsed data is encrypted using KMS if the value changed.
+9. A new YAML document is written to the original file.
 
 */
 
@@ -87,56 +109,51 @@ var editCmd = &cobra.Command{
 		}
 		return nil
 	},
-	RunE: func(_ *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if keyIdFlag != "" {
 			recrypt = true
 		}
+		err := edit.SetEnvelope(envelopeFlag)
+		if err != nil {
+			return err
+		}
 		// Work out which file we are editing.
 		filename := filenameFlag
 		if filename == "" {
-			match := regexp.MustCompile(`^([a-z0-9]+)-([a-z]+)$`).FindStringSubmatch(args[0])
-			if match == nil {
-				return errors.Errorf("unable to parse instance name %s", args[0])
-			}
-
-			filenames, err := filepath.Glob(fmt.Sprintf(`*%s/%s.yml`, match[2], match[1]))
-			if len(filenames) > 1 {
-				return errors.New("found multiple matches for filepath")
+			tenant, env, err := parseInstanceName(args[0])
+			if err != nil {
+				return err
 			}
+			filename, err = findManifestFile(tenant, env)
 			if err != nil {
 				return err
 			}
 
-			if filenames != nil {
-				filename = filenames[0]
-			} else {
+			if filename == "" {
 				// Prompt user for region when creating new file
-				regionPrompt := promptui.Prompt{
-					Label: "Enter region (eu, us, in, etc.)",
-				}
-				fileRegion, err := regionPrompt.Run()
+				fileRegion, err := promptInput("Enter region (eu, us, in, etc.)", nil, "--region", regionFlag)
 				if err != nil {
 					return err
 				}
 
-				filename = fmt.Sprintf("%s-%s/%s.yml", fileRegion, match[2], match[1])
+				filename = fmt.Sprintf("%s-%s/%s.yml", fileRegion, env, tenant)
 
 			}
 		}
 
 		// Read the file in.
 		var inStream io.Reader
+		newInstance := false
 		inFile, err := os.Open(filename)
 		if err != nil {
 			if os.IsNotExist(err) && filenameFlag == "" {
 				// No file, render the template with the default content.
-				//return error for now
-				return errors.Wrapf(err, "error reading input file %s", filename)
-				// buffer, err := createDefaultData(args[0])
-				// if err != nil {
-				// 	return errors.Wrap(err, "error creating default data")
-				// }
-				// inStream = buffer
+				buffer, err := createDefaultData(args[0])
+				if err != nil {
+					return errors.Wrap(err, "error creating default data")
+				}
+				inStream = buffer
+				newInstance = true
 			} else {
 				return errors.Wrapf(err, "error reading input file %s", filename)
 			}
@@ -151,22 +168,41 @@ var editCmd = &cobra.Command{
 			return errors.Wrap(err, "error decoding input YAML")
 		}
 
-		cfg, err := getAWSConfig("kms-grants", "us-west-1")
+		err = checkStrict(cmd, filename, inManifest)
 		if err != nil {
-			return errors.Wrapf(err, "error creating AWS session")
+			return err
 		}
 
-		// Create an Amazon KMS service client
-		kmsService := kms.NewFromConfig(cfg)
+		keyProvider, err := getKeyProvider(filename)
+		if err != nil {
+			return err
+		}
 
 		// Decrypt all the encrypted secrets.
-		err = inManifest.Decrypt(kmsService, recrypt)
+		err = inManifest.Decrypt(keyProvider, recrypt)
 		if err != nil {
 			return errors.Wrap(err, "error decrypting input manifest")
 		}
 
-		// Edit!
-		afterManifest, err := editObjects(inManifest, "")
+		// Work out the key, and check it is allowed before any editing.
+		keyId := keyIdFlag
+		if keyId == "" {
+			keyId, err = edit.FindKeyId(filename)
+			if err != nil {
+				return errors.Wrap(err, "error finding key ID")
+			}
+		}
+		err = checkManifestKeys(keyProvider, filename, inManifest, keyId, keyIdFlag != "")
+		if err != nil {
+			return err
+		}
+
+		// Edit! A new instance can be saved as is.
+		comment := ""
+		if newInstance {
+			comment = fmt.Sprintf("New instance, review and save to create %s", filename)
+		}
+		afterManifest, err := editObjects(inManifest, comment, !recrypt && !newInstance)
 		if err != nil {
 			return errors.Wrap(err, "error editing objects")
 		}
@@ -174,32 +210,116 @@ var editCmd = &cobra.Command{
 		// Match up the new objects with the old.
 		_ = afterManifest.CorrelateWith(inManifest)
 
-		// Re-encrypt anything that needs it.
-		keyId := keyIdFlag
-		if keyId == "" {
-			keyId, err = edit.FindKeyId(filename)
+		// Show what changed before anything is written.
+		if editDiffFlag || dryRunFlag {
+			printManifestDiff(afterManifest.Diff(inManifest), showValuesFlag)
+			if dryRunFlag {
+				pterm.Info.Println("Dry run, no changes written")
+				return nil
+			}
+			goAhead, err := promptConfirm(fmt.Sprintf("Write changes to %s", filename))
 			if err != nil {
-				return errors.Wrap(err, "error finding key ID")
+				return err
+			}
+			if !goAhead {
+				pterm.Info.Println("Edit cancelled. No changes made")
+				return nil
 			}
 		}
 
-		err = afterManifest.Encrypt(kmsService, keyId, keyIdFlag != "", recrypt)
+		// Re-encrypt anything that needs it.
+		err = afterManifest.Encrypt(keyProvider, keyId, keyIdFlag != "", recrypt)
 		if err != nil {
 			return errors.Wrap(err, "error encrypting after manifest")
 		}
 
 		// Write out the file again.
 		// TODO make sure the file is writable before doing all this.
-		outFile, err := os.Create(filename)
+		err = os.MkdirAll(filepath.Dir(filename), 0755)
+		if err != nil {
+			return errors.Wrapf(err, "error creating directory for %s", filename)
+		}
+		err = writeManifest(filename, afterManifest)
 		if err != nil {
-			return errors.Wrapf(err, "error opening %s for writing", filename)
+			return err
 		}
-		defer func() { _ = outFile.Close() }()
-		_ = afterManifest.Serialize(outFile)
+
+		instance := ""
+		if filenameFlag == "" {
+			instance = args[0]
+		}
+		auditLog(audit.Record{
+			Command:  "edit",
+			Instance: instance,
+			Keys:     auditKeys(afterManifest.Diff(inManifest)),
+			Details:  map[string]string{"file": filename, "recrypt": fmt.Sprint(recrypt)},
+		}, nil)
 		return nil
 	},
 }
 
+// parseInstanceName splits a <tenant>-<env> instance name.
+func parseInstanceName(instance string) (string, string, error) {
+	match := regexp.MustCompile(`^([a-z0-9]+)-([a-z]+)$`).FindStringSubmatch(instance)
+	if match == nil {
+		return "", "", errors.Errorf("unable to parse instance name %s", instance)
+	}
+	return match[1], match[2], nil
+}
+
+// findManifestFile looks for the manifest of an instance under the current
+// directory, returning "" if there isn't one.
+func findManifestFile(tenant string, env string) (string, error) {
+	filenames, err := filepath.Glob(fmt.Sprintf(`*%s/%s.yml`, env, tenant))
+	if err != nil {
+		return "", err
+	}
+	if len(filenames) > 1 {
+		return "", errors.New("found multiple matches for filepath")
+	}
+	if filenames == nil {
+		return "", nil
+	}
+	return filenames[0], nil
+}
+
+// printManifestDiff shows the added, removed and changed keys of each secret,
+// masking the values unless showValues is set.
+func printManifestDiff(diffs []edit.ObjectDiff, showValues bool) {
+	if len(diffs) == 0 {
+		pterm.Info.Println("No secret values changed")
+		return
+	}
+	for _, diff := range diffs {
+		pterm.Println(pterm.Bold.Sprintf("%s/%s:", diff.Namespace, diff.Name))
+		for _, key := range diff.Keys {
+			switch key.Action {
+			case edit.KeyAdded:
+				line := "  + " + key.Key
+				if showValues {
+					line += fmt.Sprintf(": %q", key.New)
+				}
+				pterm.Println(pterm.FgGreen.Sprint(line))
+			case edit.KeyRemoved:
+				line := "  - " + key.Key
+				if showValues {
+					line += fmt.Sprintf(": %q", key.Old)
+				}
+				pterm.Println(pterm.FgRed.Sprint(line))
+			case edit.KeyChanged:
+				line := "  ~ " + key.Key
+				if showValues {
+					line += fmt.Sprintf(": %q -> %q", key.Old, key.New)
+				}
+				if key.WhitespaceOnly() {
+					line += " (whitespace only)"
+				}
+				pterm.Println(pterm.FgYellow.Sprint(line))
+			}
+		}
+	}
+}
+
 func runEditor(filename string) error {
 	editor := os.Getenv("EDITOR")
 	if editor == "" {
@@ -223,7 +343,7 @@ func runEditor(filename string) error {
 	return nil
 }
 
-func editObjects(manifest edit.Manifest, comment string) (edit.Manifest, error) {
+func editObjects(manifest edit.Manifest, comment string, requireChange bool) (edit.Manifest, error) {
 	manifestBuf := bytes.Buffer{}
 	err := manifest.Serialize(&manifestBuf)
 	if err != nil {
@@ -276,9 +396,9 @@ func editObjects(manifest edit.Manifest, comment string) (edit.Manifest, error)
 			return nil, errors.Wrapf(err, "error reading tempfile %s", tmpfile.Name())
 		}
 
-		// If we're reencrypting ignore this equality check.
+		// If we're reencrypting or creating a new instance ignore this equality check.
 		// Check if the file was edited at all.
-		if bytes.Equal(editorBuf.Bytes(), afterBuf.Bytes()) && !recrypt {
+		if bytes.Equal(editorBuf.Bytes(), afterBuf.Bytes()) && requireChange {
 			pterm.Info.Println("Edit cancelled. No changes made")
 			os.Exit(0)
 		}
@@ -305,47 +425,181 @@ func editObjects(manifest edit.Manifest, comment string) (edit.Manifest, error)
 	}
 }
 
-// func createDefaultData(instance string) (os.Reader, error) {
-// 	target, err := kubernetes.ParseSubject(instance)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("unable to parse instance name %s", instance)
-// 	}
-// 	templateData, err := vfsutil.ReadFile(Templates, "new_instance.yml.tpl")
-// 	if err != nil {
-// 		return nil, errors.Wrap(err, "error reading new instance template")
-// 	}
-// 	template, err := template.New("new_instance.yml.tpl").Parse(string(templateData))
-// 	if err != nil {
-// 		return nil, errors.Wrap(err, "error parsing new instance template")
-// 	}
-
-// 	// Prompt user for a slack channel to alert to
-// 	slackChannelPrompt := promptui.Prompt{
-// 		Label: "Enter a slack channel name (#channel-name, blank to skip)",
-// 		Validate: func(input string) error {
-// 			if !strings.HasPrefix(input, "#") && input != "" {
-// 				return errors.New(`Channel name must have prefix "#"`)
-// 			}
-// 			return nil
-// 		},
-// 	}
-// 	slackChannelName, err := slackChannelPrompt.Run()
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	buffer := &bytes.Buffer{}
-// 	err = template.Execute(buffer, struct {
-// 		Name         string
-// 		Namespace    string
-// 		SlackChannel string
-// 	}{
-// 		Name:         instance,
-// 		Namespace:    target.Namespace,
-// 		SlackChannel: slackChannelName,
-// 	})
-// 	if err != nil {
-// 		return nil, errors.Wrap(err, "error rendering new instance template")
-// 	}
-// 	return buffer, nil
-// }
+// Characters used by Django's get_random_secret_key.
+const djangoSecretKeyChars = "abcdefghijklmnopqrstuvwxyz0123456789!@#$%^&*(-_=+)"
+
+// Slack channel names are lowercase letters, numbers, hyphens and underscores.
+var slackChannelRegexp = regexp.MustCompile(`^#[a-z0-9_-]{1,80}$`)
+
+var secretKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// newInstanceSecret is a generated secret value for the new instance template.
+type newInstanceSecret struct {
+	Key   string
+	Value string
+}
+
+// newInstanceSecrets generates the secret values of a new instance, as set in
+// the [new_instance.secrets] section of ridectl.cfg:
+//
+//	[new_instance.secrets]
+//	SECRET_KEY = django
+//	API_TOKEN = random:40
+//	SENTRY_DSN =
+//
+// django makes a Django SECRET_KEY, random:<length> an alphanumeric string, 32
+// characters without a length, and a blank generator leaves the value to fill
+// in while editing. Without the section only SECRET_KEY is generated.
+func newInstanceSecrets(ridectlConfigFile string) ([]newInstanceSecret, error) {
+	generators := map[string]string{"SECRET_KEY": "django"}
+	keys := []string{"SECRET_KEY"}
+	cfg, err := ini.LooseLoad(ridectlConfigFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", ridectlConfigFile)
+	}
+	if section, err := cfg.GetSection("new_instance.secrets"); err == nil {
+		generators = section.KeysHash()
+		keys = section.KeyStrings()
+	}
+
+	secrets := []newInstanceSecret{}
+	for _, key := range keys {
+		if !secretKeyRegexp.MatchString(key) {
+			return nil, errors.Errorf("invalid secret name %s in [new_instance.secrets] of %s", key, ridectlConfigFile)
+		}
+		generator, lengthArg, _ := strings.Cut(generators[key], ":")
+		var value string
+		switch generator {
+		case "":
+		case "django":
+			value, err = randomString(50, djangoSecretKeyChars)
+		case "random":
+			length := 32
+			if lengthArg != "" {
+				length, err = strconv.Atoi(lengthArg)
+				if err != nil || length < 1 {
+					return nil, errors.Errorf("invalid length %s for %s in [new_instance.secrets] of %s", lengthArg, key, ridectlConfigFile)
+				}
+			}
+			value, err = randomString(length, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+		default:
+			return nil, errors.Errorf("unknown generator %s for %s in [new_instance.secrets] of %s, expected django or random:<length>", generator, key, ridectlConfigFile)
+		}
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, newInstanceSecret{Key: key, Value: value})
+	}
+	return secrets, nil
+}
+
+// createDefaultData renders the new instance template. A template in
+// ~/.ridectl/new_instance.yml.tpl is used instead of the built in one if it exists.
+func createDefaultData(instance string) (io.Reader, error) {
+	target, err := kubernetes.ParseSubject(instance)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse instance name %s", instance)
+	}
+	templateData, err := os.ReadFile(filepath.Join(ridectlHomeDir, "new_instance.yml.tpl"))
+	if os.IsNotExist(err) {
+		templateData, err = TempFS.ReadFile("templates/new_instance.yml.tpl")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading new instance template")
+	}
+	tmpl, err := template.New("new_instance.yml.tpl").Funcs(template.FuncMap{
+		"randomString": func(length int) (string, error) {
+			return randomString(length, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+		},
+		"djangoSecretKey": func() (string, error) {
+			return randomString(50, djangoSecretKeyChars)
+		},
+		// JSON strings are valid quoted YAML.
+		"toJson": func(value interface{}) (string, error) {
+			out := &strings.Builder{}
+			encoder := json.NewEncoder(out)
+			encoder.SetEscapeHTML(false)
+			err := encoder.Encode(value)
+			return strings.TrimSuffix(out.String(), "\n"), err
+		},
+	}).Parse(string(templateData))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing new instance template")
+	}
+
+	// Prompt user for a slack channel to alert to, which is optional
+	validateSlackChannel := func(input string) error {
+		if !strings.HasPrefix(input, "#") && input != "" {
+			return errors.New(`Channel name must have prefix "#"`)
+		}
+		if input != "" && !slackChannelRegexp.MatchString(input) {
+			return errors.New("Channel name can only have lowercase letters, numbers, hyphens and underscores")
+		}
+		return nil
+	}
+	slackChannelName := slackChannelFlag
+	if slackChannelName == "" && !nonInteractiveFlag {
+		slackChannelName, err = promptInput("Enter a slack channel name (#channel-name, blank to skip)", validateSlackChannel, "--slack-channel", "")
+		if err != nil {
+			return nil, err
+		}
+	} else if err = validateSlackChannel(slackChannelName); err != nil {
+		return nil, errors.Wrap(err, "invalid --slack-channel")
+	}
+
+	secrets, err := newInstanceSecrets(ridectlConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := &bytes.Buffer{}
+	err = tmpl.Execute(buffer, struct {
+		Name         string
+		Namespace    string
+		SlackChannel string
+		Secrets      []newInstanceSecret
+	}{
+		Name:         target.Name,
+		Namespace:    target.Namespace,
+		SlackChannel: slackChannelName,
+		Secrets:      secrets,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error rendering new instance template")
+	}
+	return buffer, nil
+}
+
+// randomString returns a random string of the given length, using crypto/rand.
+func randomString(length int, chars string) (string, error) {
+	out := make([]byte, length)
+	max := big.NewInt(int64(len(chars)))
+	for i := range out {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", errors.Wrap(err, "error generating random string")
+		}
+		out[i] = chars[n.Int64()]
+	}
+	return string(out), nil
+}
+
+// checkManifestKeys checks the keys a manifest will be encrypted with against
+// the policies in .keys.yml. Secrets keep the key they were encrypted with,
+// unless it is forced.
+func checkManifestKeys(keyProvider edit.KeyProvider, filename string, manifest edit.Manifest, keyId string, forceKeyId bool) error {
+	keyIds := []string{}
+	if keyId != "" {
+		keyIds = append(keyIds, keyId)
+	}
+	for _, obj := range manifest {
+		if !forceKeyId && obj.KeyId != "" && !slices.Contains(keyIds, obj.KeyId) {
+			keyIds = append(keyIds, obj.KeyId)
+		}
+	}
+	err := edit.CheckKeyPolicy(keyProvider, filename, keyIds...)
+	if err != nil {
+		return errors.Wrap(err, "use -k to pick an allowed key")
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edit
+
+import (
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Default number of objects or files decrypted at once, which can be
+// changed with RIDECTL_DECRYPT_WORKERS.
+const defaultDecryptWorkers = 8
+
+// dataKey is a decrypted data key, or one being decrypted.
+type dataKey struct {
+	done         chan struct{}
+	plainDataKey *[32]byte
+	keyId        string
+	err          error
+}
+
+// dataKeyCache holds decrypted data keys by their ciphertext, so each one is
+// only sent to the key provider once per run. Ciphertexts are unique to the
+// provider which made them, so one cache serves every provider.
+type dataKeyCache struct {
+	mu   sync.Mutex
+	keys map[string]*dataKey
+}
+
+var dataKeys = &dataKeyCache{keys: map[string]*dataKey{}}
+
+// get returns the decrypted data key, waiting on any decrypt of the same
+// data key already in flight rather than making another call. Failures are
+// not cached so later calls can retry.
+func (c *dataKeyCache) get(cipherDataKey []byte, decrypt func() (*[32]byte, string, error)) (*[32]byte, string, error) {
+	c.mu.Lock()
+	k, ok := c.keys[string(cipherDataKey)]
+	if ok {
+		c.mu.Unlock()
+		<-k.done
+		return k.plainDataKey, k.keyId, k.err
+	}
+	k = &dataKey{done: make(chan struct{})}
+	c.keys[string(cipherDataKey)] = k
+	c.mu.Unlock()
+
+	k.plainDataKey, k.keyId, k.err = decrypt()
+	if k.err != nil {
+		c.mu.Lock()
+		delete(c.keys, string(cipherDataKey))
+		c.mu.Unlock()
+	}
+	close(k.done)
+	return k.plainDataKey, k.keyId, k.err
+}
+
+// decryptWorkers returns the size of the worker pool for decrypting.
+func decryptWorkers() int {
+	workers, err := strconv.Atoi(os.Getenv("RIDECTL_DECRYPT_WORKERS"))
+	if err != nil || workers < 1 {
+		return defaultDecryptWorkers
+	}
+	return workers
+}
+
+// ForEachParallel calls fn for 0 to count-1 from a bounded pool of workers.
+// All calls are made even if some fail, and the error for the lowest index
+// is returned so failures are reported the same way every run.
+func ForEachParallel(count int, fn func(i int) error) error {
+	errs := make([]error, count)
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < min(decryptWorkers(), count); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
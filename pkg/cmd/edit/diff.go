@@ -0,0 +1,106 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	KeyAdded   = "added"
+	KeyRemoved = "removed"
+	KeyChanged = "changed"
+)
+
+type KeyDiff struct {
+	Key    string
+	Action string
+	Old    string
+	New    string
+}
+
+// WhitespaceOnly is true for a changed value that only differs in whitespace.
+func (d KeyDiff) WhitespaceOnly() bool {
+	return d.Action == KeyChanged && strings.Join(strings.Fields(d.Old), "") == strings.Join(strings.Fields(d.New), "")
+}
+
+type ObjectDiff struct {
+	Namespace string
+	Name      string
+	Keys      []KeyDiff
+}
+
+// Diff compares the decrypted data of each secret with the original it was
+// correlated with, returning the objects which have any added, removed or
+// changed keys. Secrets missing from the new manifest have all keys removed.
+func (m Manifest) Diff(origManifest Manifest) []ObjectDiff {
+	diffs := []ObjectDiff{}
+	seen := map[string]bool{}
+	for _, obj := range m {
+		if obj.Kind == "" {
+			continue
+		}
+		seen[fmt.Sprintf("%s/%s", obj.Meta.GetNamespace(), obj.Meta.GetName())] = true
+		var before, after map[string]string
+		if obj.OrigDec != nil {
+			before = obj.OrigDec.Data
+		}
+		if obj.AfterDec != nil {
+			after = obj.AfterDec.Data
+		}
+		diff := ObjectDiff{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName(), Keys: DiffData(before, after)}
+		if len(diff.Keys) > 0 {
+			diffs = append(diffs, diff)
+		}
+	}
+	for _, obj := range origManifest {
+		if obj.Kind == "" || seen[fmt.Sprintf("%s/%s", obj.Meta.GetNamespace(), obj.Meta.GetName())] {
+			continue
+		}
+		var before map[string]string
+		if obj.OrigDec != nil {
+			before = obj.OrigDec.Data
+		}
+		diff := ObjectDiff{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName(), Keys: DiffData(before, nil)}
+		if len(diff.Keys) > 0 {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs
+}
+
+// DiffData compares two sets of secret values, sorted by key.
+func DiffData(before map[string]string, after map[string]string) []KeyDiff {
+	keys := []KeyDiff{}
+	for key, value := range after {
+		origValue, ok := before[key]
+		if !ok {
+			keys = append(keys, KeyDiff{Key: key, Action: KeyAdded, New: value})
+		} else if origValue != value {
+			keys = append(keys, KeyDiff{Key: key, Action: KeyChanged, Old: origValue, New: value})
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, KeyDiff{Key: key, Action: KeyRemoved, Old: value})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+/*
+
+Encrypted values are stored in one of two envelope formats.
+
+v1 is "crypto " followed by the base64 of a gob encoded Payload. This can only
+be read from Go.
+
+v2 is "crypto:v2:" followed by the base64 of a JSON object:
+
+  {
+    "keyId": "alias/microservices_dev",
+    "alg": "xchacha20-poly1305",
+    "dataKey": "<base64 data key, encrypted by KMS>",
+    "nonce": "<base64 24 byte nonce>",
+    "ciphertext": "<base64 sealed value>"
+  }
+
+To decrypt a v2 value, decrypt dataKey with KMS using the encryption context
+{"RidecellOperator": "true"}, then open ciphertext with the 32 byte data key
+and the nonce using alg:
+
+  xchacha20-poly1305: XChaCha20-Poly1305 AEAD, with the additional data
+    "<namespace>/<name>/<key>" of the EncryptedSecret value. This binds the
+    value to where it is stored, so it can't be moved to another secret or key.
+  secretbox: NaCl secretbox (XSalsa20-Poly1305), with no additional data.
+    Used for files encrypted with ridectl encrypt, and by v1.
+
+keyId names the KMS key the data key was generated with, KMS does not need it
+to decrypt.
+
+Only xchacha20-poly1305 values are bound. v1 and secretbox values, and alg
+itself, are not authenticated, so a value from elsewhere, or an older v1
+value, could be swapped in for a bound one. Once a manifest is on v2, strict
+mode (--strict, or strict: true in .keys.yml) refuses to decrypt it while any
+value is unbound, and ridectl lint reports them.
+
+*/
+
+const (
+	EnvelopeV1 = "v1"
+	EnvelopeV2 = "v2"
+
+	envelopeV1Prefix = "crypto "
+	envelopeV2Prefix = "crypto:v2:"
+
+	algorithmSecretbox = "secretbox"
+	algorithmXChaCha   = "xchacha20-poly1305"
+)
+
+// ErrTampered is returned when a value fails authentication after its data
+// key was decrypted, meaning the ciphertext was changed or moved from
+// somewhere else.
+var ErrTampered = errors.New("value failed authentication, it was modified or moved from another secret or key")
+
+// WriteEnvelope is the envelope format used for newly encrypted values.
+// Values which did not change keep their existing format.
+var WriteEnvelope = EnvelopeV1
+
+// envelope is the JSON object in a v2 value.
+type envelope struct {
+	KeyId      string `json:"keyId"`
+	Algorithm  string `json:"alg"`
+	DataKey    []byte `json:"dataKey"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// SetEnvelope picks the envelope format to write new values in.
+func SetEnvelope(version string) error {
+	if version != EnvelopeV1 && version != EnvelopeV2 {
+		return errors.Errorf("unknown envelope version %s, expected %s or %s", version, EnvelopeV1, EnvelopeV2)
+	}
+	WriteEnvelope = version
+	return nil
+}
+
+// IsPayload checks if a value is encrypted with a data key in one of the
+// envelope formats, rather than directly with KMS.
+func IsPayload(value string) bool {
+	return strings.HasPrefix(value, "crypto")
+}
+
+// AdditionalData returns the data an EncryptedSecret value is bound to.
+func AdditionalData(namespace string, name string, key string) []byte {
+	return []byte(namespace + "/" + name + "/" + key)
+}
+
+// SealPayload encrypts plaintext into the payload message with the data key,
+// returning the algorithm used. Values with additional data are bound to it
+// using XChaCha20-Poly1305, which needs the v2 envelope. Otherwise NaCl
+// secretbox is used.
+func SealPayload(p *Payload, plaintext []byte, plainDataKey *[32]byte, additionalData []byte) (string, error) {
+	if additionalData == nil {
+		p.Message = secretbox.Seal(nil, plaintext, p.Nonce, plainDataKey)
+		return algorithmSecretbox, nil
+	}
+	aead, err := chacha20poly1305.NewX(plainDataKey[:])
+	if err != nil {
+		return "", err
+	}
+	p.Message = aead.Seal(nil, p.Nonce[:], plaintext, additionalData)
+	return algorithmXChaCha, nil
+}
+
+// OpenPayload decrypts the payload message with the data key. The additional
+// data is only checked for values bound to it.
+func OpenPayload(p *Payload, algorithm string, plainDataKey *[32]byte, additionalData []byte) ([]byte, error) {
+	switch algorithm {
+	case algorithmSecretbox:
+		plaintext, ok := secretbox.Open(nil, p.Message, p.Nonce, plainDataKey)
+		if !ok {
+			return nil, ErrTampered
+		}
+		return plaintext, nil
+	case algorithmXChaCha:
+		aead, err := chacha20poly1305.NewX(plainDataKey[:])
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := aead.Open(nil, p.Nonce[:], p.Message, additionalData)
+		if err != nil {
+			return nil, ErrTampered
+		}
+		return plaintext, nil
+	}
+	return nil, errors.Errorf("unsupported envelope algorithm %s", algorithm)
+}
+
+// DecodePayload reads a value in either envelope format, returning the
+// payload and the algorithm it was sealed with. A bare base64 gob Payload, as
+// written by ridectl encrypt, is read as v1.
+func DecodePayload(value string) (*Payload, string, error) {
+	if encoded, ok := strings.CutPrefix(value, envelopeV2Prefix); ok {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "error base64 decoding envelope")
+		}
+		e := &envelope{}
+		err = json.Unmarshal(raw, e)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "error decoding envelope")
+		}
+		if e.Algorithm != algorithmSecretbox && e.Algorithm != algorithmXChaCha {
+			return nil, "", errors.Errorf("unsupported envelope algorithm %s", e.Algorithm)
+		}
+		if len(e.Nonce) != nonceLength {
+			return nil, "", errors.New("invalid nonce in envelope")
+		}
+		p := &Payload{Key: e.DataKey, Nonce: &[nonceLength]byte{}, Message: e.Ciphertext}
+		copy(p.Nonce[:], e.Nonce)
+		return p, e.Algorithm, nil
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return nil, "", errors.New("empty value")
+	}
+	raw, err := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error base64 decoding value")
+	}
+	p := &Payload{}
+	err = gob.NewDecoder(bytes.NewReader(raw)).Decode(p)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error decoding payload")
+	}
+	if p.Nonce == nil {
+		return nil, "", errors.New("missing nonce in payload")
+	}
+	return p, algorithmSecretbox, nil
+}
+
+// decodeEnvelope returns the JSON object of a v2 value, or nil for other or
+// invalid values.
+func decodeEnvelope(value string) *envelope {
+	encoded, ok := strings.CutPrefix(value, envelopeV2Prefix)
+	if !ok {
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+	e := &envelope{}
+	if json.Unmarshal(raw, e) != nil {
+		return nil
+	}
+	return e
+}
+
+// EnvelopeKeyId returns the KMS key recorded in a v2 value, or "" for
+// other values, where the key is only known after decrypting.
+func EnvelopeKeyId(value string) string {
+	e := decodeEnvelope(value)
+	if e == nil {
+		return ""
+	}
+	return e.KeyId
+}
+
+// IsBound checks if a value is bound to where it is stored, see
+// AdditionalData.
+func IsBound(value string) bool {
+	e := decodeEnvelope(value)
+	return e != nil && e.Algorithm == algorithmXChaCha
+}
+
+// EncodePayload writes a value in the given envelope format. keyId is only
+// recorded in v2 values.
+func EncodePayload(p *Payload, keyId string, algorithm string, version string) (string, error) {
+	if version == EnvelopeV2 {
+		raw, err := json.Marshal(&envelope{
+			KeyId:      keyId,
+			Algorithm:  algorithm,
+			DataKey:    p.Key,
+			Nonce:      p.Nonce[:],
+			Ciphertext: p.Message,
+		})
+		if err != nil {
+			return "", errors.Wrap(err, "error encoding envelope")
+		}
+		return envelopeV2Prefix + base64.StdEncoding.EncodeToString(raw), nil
+	}
+
+	if algorithm != algorithmSecretbox {
+		return "", errors.Errorf("%s values need the %s envelope", algorithm, EnvelopeV2)
+	}
+	buf := &bytes.Buffer{}
+	err := gob.NewEncoder(buf).Encode(p)
+	if err != nil {
+		return "", errors.Wrap(err, "error encoding payload")
+	}
+	return envelopeV1Prefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
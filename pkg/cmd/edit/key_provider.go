@@ -0,0 +1,87 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edit
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// KeyProvider is the master key service used to generate and decrypt data keys.
+type KeyProvider interface {
+	// GenerateDataKey returns a new 32 byte data key along with a copy of it
+	// encrypted under the given master key.
+	GenerateDataKey(keyId string) ([]byte, []byte, error)
+	// Decrypt decrypts a ciphertext from the provider, returning the plaintext
+	// and the ID of the master key it was encrypted with.
+	Decrypt(ciphertext []byte) ([]byte, string, error)
+	// ListAliases returns the aliases of a master key.
+	ListAliases(keyId string) ([]string, error)
+}
+
+// Encryption context used for all KMS operations.
+var kmsEncryptionContext = map[string]string{
+	"RidecellOperator": "true",
+}
+
+type kmsKeyProvider struct {
+	client *kms.Client
+}
+
+// NewKMSKeyProvider returns a KeyProvider backed by AWS KMS.
+func NewKMSKeyProvider(client *kms.Client) KeyProvider {
+	return &kmsKeyProvider{client: client}
+}
+
+func (p *kmsKeyProvider) GenerateDataKey(keyId string) ([]byte, []byte, error) {
+	rsp, err := p.client.GenerateDataKey(context.TODO(), &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(keyId),
+		NumberOfBytes:     aws.Int32(32),
+		EncryptionContext: kmsEncryptionContext,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return rsp.Plaintext, rsp.CiphertextBlob, nil
+}
+
+func (p *kmsKeyProvider) Decrypt(ciphertext []byte) ([]byte, string, error) {
+	rsp, err := p.client.Decrypt(context.TODO(), &kms.DecryptInput{
+		CiphertextBlob:    ciphertext,
+		EncryptionContext: kmsEncryptionContext,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return rsp.Plaintext, *rsp.KeyId, nil
+}
+
+func (p *kmsKeyProvider) ListAliases(keyId string) ([]string, error) {
+	rsp, err := p.client.ListAliases(context.TODO(), &kms.ListAliasesInput{
+		KeyId: aws.String(keyId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	aliases := []string{}
+	for _, alias := range rsp.Aliases {
+		aliases = append(aliases, *alias.AliasName)
+	}
+	return aliases, nil
+}
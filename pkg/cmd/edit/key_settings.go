@@ -16,53 +16,517 @@ package edit
 import (
 	"os"
 	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
+	yamlv3 "go.yaml.in/yaml/v3"
 	"gopkg.in/yaml.v2"
 )
 
-func FindKeyId(manifestPath string) (string, error) {
-	keysPath := path.Join(manifestPath, "..", ".keys.yml")
-	keysF, err := os.Open(keysPath)
+/*
+
+.keys.yml picks the key used to encrypt the manifests it covers. The original
+format maps name fragments to keys. A manifest uses the key of the longest
+fragment in its file name, or default:
+
+  default: alias/microservices_dev
+  prod: alias/microservices_prod
+  key_provider: kms
+
+Version 2 has a list of rules instead:
+
+  version: 2
+  key_provider: kms
+  default: alias/microservices_dev
+  rules:
+  - match: "*prod*"
+    key: alias/microservices_prod
+    priority: 10
+    env: prod
+    deny_keys: ["*_dev"]
+  - regex: '^us-(uat|qa)/'
+    key: alias/microservices_uat
+    env: uat
+
+A rule applies to a manifest if its match glob or regex does. Globs without a /
+match the file name, others and regexes match the path from the directory of
+.keys.yml. The key comes from the applying rule with the highest priority,
+the first in the file on a tie, or default. env tags the manifests a rule
+applies to with their environment.
+
+allow_keys and deny_keys are policies, globs of the keys manifests a rule
+applies to may or may not be encrypted with. The alias/ prefix is optional.
+Policies are checked for every rule that applies, not just the one picking
+the key, so rules can have a policy and no key.
+
+strict: true refuses to decrypt the manifests a version 2 file covers while
+any of their values are not bound to their secrets, see envelope.go. Set it
+once they have all been re-encrypted with the v2 envelope.
+
+The original format only covers manifests in its own directory. A version 2
+file also covers the directories below it without a .keys.yml, up to the top
+of the git repository.
+
+*/
+
+const keySettingsFile = ".keys.yml"
+
+// Entry in .keys.yml naming the key provider to use for manifests next to it.
+const keyProviderSetting = "key_provider"
+
+// KeySettings is a .keys.yml file. The original format is read as version 1,
+// with a rule for each entry.
+type KeySettings struct {
+	Version     int       `yaml:"version"`
+	KeyProvider string    `yaml:"key_provider,omitempty"`
+	Default     string    `yaml:"default,omitempty"`
+	Strict      bool      `yaml:"strict,omitempty"`
+	Rules       []KeyRule `yaml:"rules,omitempty"`
+
+	// Path of the file, or where it would be next to the manifest when Found
+	// is false.
+	Path  string `yaml:"-"`
+	Found bool   `yaml:"-"`
+}
+
+// KeyRule is a rule in a version 2 .keys.yml.
+type KeyRule struct {
+	Name      string   `yaml:"name,omitempty"`
+	Match     string   `yaml:"match,omitempty"`
+	Regex     string   `yaml:"regex,omitempty"`
+	Key       string   `yaml:"key,omitempty"`
+	Priority  int      `yaml:"priority,omitempty"`
+	Env       string   `yaml:"env,omitempty"`
+	AllowKeys []string `yaml:"allow_keys,omitempty"`
+	DenyKeys  []string `yaml:"deny_keys,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// String names a rule in messages.
+func (r *KeyRule) String() string {
+	switch {
+	case r.Name != "":
+		return r.Name
+	case r.Match != "":
+		return "match " + r.Match
+	}
+	return "regex " + r.Regex
+}
+
+// Applies checks if the rule applies to a manifest, given its slash separated
+// path from the directory of .keys.yml.
+func (r *KeyRule) Applies(rel string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(rel)
+	}
+	return MatchGlob(r.Match, rel)
+}
+
+// MatchGlob matches a slash separated relative path with a glob. Globs
+// without a / match the file name anywhere, others match the whole path, and
+// globs ending in / match everything under that path.
+func MatchGlob(glob string, rel string) bool {
+	glob = strings.TrimPrefix(glob, "/")
+	var matched bool
+	switch {
+	case strings.HasSuffix(glob, "/"):
+		matched = strings.HasPrefix(rel, glob)
+	case strings.Contains(glob, "/"):
+		matched, _ = path.Match(glob, rel)
+	default:
+		matched, _ = path.Match(glob, path.Base(rel))
+	}
+	return matched
+}
+
+// matchKey matches a key ID or alias with policy globs.
+func matchKey(globs []string, keyIds []string) bool {
+	for _, glob := range globs {
+		for _, keyId := range keyIds {
+			if matched, _ := path.Match(glob, keyId); matched {
+				return true
+			}
+			if matched, _ := path.Match(strings.TrimPrefix(glob, "alias/"), strings.TrimPrefix(keyId, "alias/")); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadKeySettings reads a .keys.yml file in either format, checking it is
+// valid. A missing file has no settings.
+func ReadKeySettings(keysPath string) (*KeySettings, error) {
+	settings := &KeySettings{Version: 1, Path: keysPath}
+	raw, err := os.ReadFile(keysPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			// If the file doesn't exist, the key ID from the file is "". This allows
+			// If the file doesn't exist, there are no settings. This allows
 			// editing a file with existing encrypted data without worrying about the key file.
-			return "", nil
+			return settings, nil
+		}
+		return nil, errors.Wrapf(err, "error loading key settings file %s", keysPath)
+	}
+	settings.Found = true
+
+	version := struct {
+		Version interface{} `yaml:"version"`
+	}{}
+	err = yaml.Unmarshal(raw, &version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error decoding key settings YAML %s", keysPath)
+	}
+	switch version.Version {
+	case nil, 1:
+		// The original map format, which may say it is version 1.
+		keys := yaml.MapSlice{}
+		err = yaml.Unmarshal(raw, &keys)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error decoding key settings YAML %s", keysPath)
+		}
+		for _, m := range keys {
+			if m.Key == "version" {
+				continue
+			}
+			mKey, ok := m.Key.(string)
+			mValue, ok2 := m.Value.(string)
+			if !ok || !ok2 {
+				return nil, errors.Errorf("error decoding key settings YAML %s: %v is not a key", keysPath, m.Key)
+			}
+			switch mKey {
+			case "default":
+				settings.Default = mValue
+			case keyProviderSetting:
+				settings.KeyProvider = mValue
+			default:
+				// Longer names are more specific.
+				settings.Rules = append(settings.Rules, KeyRule{Name: mKey, Match: "*" + mKey + "*", Key: mValue, Priority: len(mKey)})
+			}
+		}
+	case 2:
+		err = yaml.UnmarshalStrict(raw, settings)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error decoding key settings YAML %s", keysPath)
+		}
+	default:
+		return nil, errors.Errorf("unknown version %v in %s, expected 1 or 2", version.Version, keysPath)
+	}
+
+	for i := range settings.Rules {
+		err = settings.Rules[i].compile()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid rule %d in %s", i+1, keysPath)
+		}
+	}
+	return settings, nil
+}
+
+// compile checks a rule is valid, and compiles its regex.
+func (r *KeyRule) compile() error {
+	if (r.Match == "") == (r.Regex == "") {
+		return errors.Errorf("%s must have one of match or regex", r)
+	}
+	if r.Key == "" && len(r.AllowKeys) == 0 && len(r.DenyKeys) == 0 {
+		return errors.Errorf("%s must have a key or a policy", r)
+	}
+	globs := append([]string{strings.TrimSuffix(r.Match, "/")}, r.AllowKeys...)
+	for _, glob := range append(globs, r.DenyKeys...) {
+		_, err := path.Match(glob, "")
+		if err != nil {
+			return errors.Wrapf(err, "invalid glob %s in %s", glob, r)
+		}
+	}
+	if r.Regex != "" {
+		var err error
+		r.regex, err = regexp.Compile(r.Regex)
+		if err != nil {
+			return errors.Wrapf(err, "invalid regex in %s", r)
 		}
-		return "", errors.Wrapf(err, "error loading key settings file %s", keysPath)
 	}
-	decoder := yaml.NewDecoder(keysF)
-	keys := yaml.MapSlice{}
-	err = decoder.Decode(&keys)
+	return nil
+}
+
+// LoadKeySettings finds the .keys.yml covering a manifest.
+func LoadKeySettings(manifestPath string) (*KeySettings, error) {
+	settings, err := ReadKeySettings(path.Join(manifestPath, "..", keySettingsFile))
+	if err != nil || settings.Found {
+		return settings, err
+	}
+
+	// Look for a version 2 file further up.
+	dir, err := filepath.Abs(filepath.Dir(manifestPath))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error finding directory of %s", manifestPath)
+	}
+	for {
+		_, err = os.Stat(filepath.Join(dir, ".git"))
+		parent := filepath.Dir(dir)
+		if err == nil || parent == dir {
+			return settings, nil
+		}
+		dir = parent
+		parentSettings, err := ReadKeySettings(filepath.Join(dir, keySettingsFile))
+		if err != nil {
+			return nil, err
+		}
+		if parentSettings.Found {
+			if parentSettings.Version < 2 {
+				return settings, nil
+			}
+			return parentSettings, nil
+		}
+	}
+}
+
+// relPath returns the slash separated path of a manifest from the directory
+// of .keys.yml.
+func (s *KeySettings) relPath(manifestPath string) string {
+	keysDir, err := filepath.Abs(filepath.Dir(s.Path))
+	if err != nil {
+		return filepath.ToSlash(filepath.Base(manifestPath))
+	}
+	manifestPath, err = filepath.Abs(manifestPath)
 	if err != nil {
-		return "", errors.Wrap(err, "error decoding key settings YAML")
-	}
-	matchKey := ""
-	matchValue := ""
-	defaultValue := ""
-	matchTarget := path.Base(manifestPath)
-	for _, m := range keys {
-		mKey := m.Key.(string)
-		mValue := m.Value.(string)
-		if mKey == "default" {
-			// Handled later.
-			defaultValue = mValue
+		return filepath.ToSlash(filepath.Base(manifestPath))
+	}
+	rel, err := filepath.Rel(keysDir, manifestPath)
+	if err != nil {
+		return filepath.ToSlash(filepath.Base(manifestPath))
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Applying returns the rules applying to a manifest, highest priority first
+// and in file order on a tie.
+func (s *KeySettings) Applying(manifestPath string) []*KeyRule {
+	rel := s.relPath(manifestPath)
+	rules := []*KeyRule{}
+	for i := range s.Rules {
+		if s.Rules[i].Applies(rel) {
+			rules = append(rules, &s.Rules[i])
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+	return rules
+}
+
+// Find returns the rule picking the key for a manifest, or nil if the default
+// is used.
+func (s *KeySettings) Find(manifestPath string) *KeyRule {
+	for _, rule := range s.Applying(manifestPath) {
+		if rule.Key != "" {
+			return rule
+		}
+	}
+	return nil
+}
+
+// KeyId returns the key to encrypt a manifest with, or "" if there is none.
+func (s *KeySettings) KeyId(manifestPath string) string {
+	rule := s.Find(manifestPath)
+	if rule == nil {
+		return s.Default
+	}
+	return rule.Key
+}
+
+// Env returns the environment a manifest is tagged with, or "" if it isn't.
+func (s *KeySettings) Env(manifestPath string) string {
+	for _, rule := range s.Applying(manifestPath) {
+		if rule.Env != "" {
+			return rule.Env
+		}
+	}
+	return ""
+}
+
+// CheckKey checks a key against the policies of the rules applying to a
+// manifest. Key IDs which are not aliases are also checked by their aliases
+// when a key provider is given.
+func (s *KeySettings) CheckKey(keyProvider KeyProvider, manifestPath string, keyId string) error {
+	keyIds := []string{keyId}
+	listed := keyProvider == nil || strings.HasPrefix(keyId, "alias/")
+	for _, rule := range s.Applying(manifestPath) {
+		if len(rule.AllowKeys) == 0 && len(rule.DenyKeys) == 0 {
 			continue
 		}
-		matched, err := path.Match("*"+mKey+"*", matchTarget)
+		if !listed {
+			aliases, err := keyProvider.ListAliases(keyId)
+			if err == nil {
+				keyIds = append(keyIds, aliases...)
+			}
+			listed = true
+		}
+		if len(rule.AllowKeys) > 0 && !matchKey(rule.AllowKeys, keyIds) {
+			return errors.Errorf("%s may not be encrypted with %s, %s in %s only allows %s", manifestPath, keyId, rule, s.Path, strings.Join(rule.AllowKeys, ", "))
+		}
+		if matchKey(rule.DenyKeys, keyIds) {
+			return errors.Errorf("%s may not be encrypted with %s, %s in %s denies %s", manifestPath, keyId, rule, s.Path, strings.Join(rule.DenyKeys, ", "))
+		}
+	}
+	return nil
+}
+
+func FindKeyId(manifestPath string) (string, error) {
+	settings, err := LoadKeySettings(manifestPath)
+	if err != nil {
+		return "", err
+	}
+	return settings.KeyId(manifestPath), nil
+}
+
+// CheckKeyPolicy checks the keys a manifest is encrypted with against the
+// policies in its .keys.yml.
+func CheckKeyPolicy(keyProvider KeyProvider, manifestPath string, keyIds ...string) error {
+	settings, err := LoadKeySettings(manifestPath)
+	if err != nil {
+		return err
+	}
+	for _, keyId := range keyIds {
+		err = settings.CheckKey(keyProvider, manifestPath, keyId)
 		if err != nil {
-			return "", errors.Wrapf(err, "error matching key %s", mKey)
+			return err
+		}
+	}
+	return nil
+}
+
+// FindStrict checks if strict mode is set in the .keys.yml for a manifest.
+func FindStrict(manifestPath string) (bool, error) {
+	settings, err := LoadKeySettings(manifestPath)
+	if err != nil {
+		return false, err
+	}
+	return settings.Strict, nil
+}
+
+// FindKeyProvider returns the key provider set in .keys.yml, if any. A local
+// keys file path is made relative to the directory of .keys.yml.
+func FindKeyProvider(manifestPath string) (string, error) {
+	settings, err := LoadKeySettings(manifestPath)
+	if err != nil {
+		return "", err
+	}
+	provider := settings.KeyProvider
+	if localPath, ok := strings.CutPrefix(provider, "local:"); ok && !path.IsAbs(localPath) {
+		provider = "local:" + path.Join(path.Dir(settings.Path), localPath)
+	}
+	return provider, nil
+}
+
+// FindKeySettingsUsing returns the path of the .keys.yml for a manifest and
+// the names of its entries selecting the given key.
+func FindKeySettingsUsing(manifestPath string, keyId string) (string, []string, error) {
+	settings, err := LoadKeySettings(manifestPath)
+	if err != nil {
+		return path.Join(manifestPath, "..", keySettingsFile), nil, err
+	}
+	names := []string{}
+	if settings.Default == keyId {
+		names = append(names, "default")
+	}
+	for _, rule := range settings.Rules {
+		if rule.Key == keyId {
+			names = append(names, rule.String())
 		}
-		if matched {
-			if len(mKey) > len(matchKey) {
-				matchKey = mKey
-				matchValue = mValue
+	}
+	return settings.Path, names, nil
+}
+
+// keySettingsEntry is a key in a .keys.yml file, for rewriting.
+type keySettingsEntry struct {
+	key   *yamlv3.Node
+	value *yamlv3.Node
+	flow  bool
+}
+
+// keySettingsEntries returns the keys in a .keys.yml file.
+func keySettingsEntries(root *yamlv3.Node, version int) []keySettingsEntry {
+	entries := []keySettingsEntry{}
+	flow := root.Style&yamlv3.FlowStyle != 0
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch {
+		case version < 2 && key.Value != keyProviderSetting:
+			entries = append(entries, keySettingsEntry{key, value, flow})
+		case version >= 2 && key.Value == "default":
+			entries = append(entries, keySettingsEntry{key, value, flow})
+		case version >= 2 && key.Value == "rules" && value.Kind == yamlv3.SequenceNode:
+			for _, rule := range value.Content {
+				if rule.Kind != yamlv3.MappingNode {
+					continue
+				}
+				for j := 0; j+1 < len(rule.Content); j += 2 {
+					if rule.Content[j].Value == "key" {
+						entries = append(entries, keySettingsEntry{rule.Content[j], rule.Content[j+1], flow || rule.Style&yamlv3.FlowStyle != 0})
+					}
+				}
 			}
 		}
 	}
-	if matchValue == "" {
-		return defaultValue, nil
+	return entries
+}
+
+// UpdateKeySettings rewrites the .keys.yml entries for a manifest which select
+// the from key to select the to key instead, leaving the rest of the file as
+// it was. It returns the path of .keys.yml and the number of entries changed.
+func UpdateKeySettings(manifestPath string, from string, to string) (string, int, error) {
+	settings, err := LoadKeySettings(manifestPath)
+	if err != nil || !settings.Found {
+		return "", 0, err
+	}
+	keysPath := settings.Path
+	raw, err := os.ReadFile(keysPath)
+	if err != nil {
+		return keysPath, 0, errors.Wrapf(err, "error loading key settings file %s", keysPath)
+	}
+	doc := &yamlv3.Node{}
+	err = yamlv3.Unmarshal(raw, doc)
+	if err != nil {
+		return keysPath, 0, errors.Wrap(err, "error decoding key settings YAML")
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yamlv3.MappingNode {
+		return keysPath, 0, nil
+	}
+	lines := newLineIndex(raw)
+
+	edits := []textEdit{}
+	for _, entry := range keySettingsEntries(doc.Content[0], settings.Version) {
+		if entry.value.Kind != yamlv3.ScalarNode || entry.value.Value != from {
+			continue
+		}
+		loc, err := newKeysLocation(raw, lines, entry.key, entry.value, entry.flow, 0)
+		if err != nil {
+			return keysPath, 0, errors.Wrapf(err, "error locating value for %s", entry.key.Value)
+		}
+		if edit, ok := loc.replace(to); ok {
+			edits = append(edits, edit)
+		}
+	}
+	if len(edits) == 0 {
+		return keysPath, 0, nil
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].Start < edits[j].Start
+	})
+
+	out := []byte{}
+	carry := 0
+	for _, edit := range edits {
+		out = append(out, raw[carry:edit.Start]...)
+		out = append(out, edit.Text...)
+		carry = edit.End
+	}
+	out = append(out, raw[carry:]...)
+	err = os.WriteFile(keysPath, out, 0644)
+	if err != nil {
+		return keysPath, 0, errors.Wrapf(err, "error writing %s", keysPath)
 	}
-	return matchValue, nil
+	return keysPath, len(edits), nil
 }
@@ -0,0 +1,60 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadKeySettingsVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		keyId   string
+		wantErr bool
+	}{
+		{"original", "default: alias/dev\nprod: alias/prod\n", "alias/prod", false},
+		{"version 1", "version: 1\ndefault: alias/dev\nprod: alias/prod\n", "alias/prod", false},
+		{"version 2", "version: 2\ndefault: alias/dev\nrules:\n  - match: '*prod*'\n    key: alias/prod\n", "alias/prod", false},
+		{"version 3", "version: 3\ndefault: alias/dev\n", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keysPath := filepath.Join(t.TempDir(), keySettingsFile)
+			err := os.WriteFile(keysPath, []byte(test.text), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			settings, err := ReadKeySettings(keysPath)
+			if test.wantErr {
+				if err == nil {
+					t.Error("no error for an unknown version")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyId := settings.KeyId(filepath.Join(filepath.Dir(keysPath), "darwin-prod.yml"))
+			if keyId != test.keyId {
+				t.Errorf("got key %s, want %s", keyId, test.keyId)
+			}
+		})
+	}
+}
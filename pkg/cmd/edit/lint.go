@@ -0,0 +1,103 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edit
+
+import (
+	"fmt"
+)
+
+// Lint rules.
+const (
+	RuleDecode          = "decode"
+	RuleDecryptedSecret = "decrypted-secret"
+	RuleNotEncrypted    = "not-encrypted"
+	RuleKeyMismatch     = "key-mismatch"
+	RuleDecrypt         = "decrypt"
+	RuleDuplicate       = "duplicate"
+	RuleUnbound         = "unbound"
+)
+
+// Problem is a lint finding in a manifest file.
+type Problem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Object  string `json:"object,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+// LintManifest decodes each document of a manifest file on its own, so one
+// bad object doesn't hide problems in the rest. It returns the objects which
+// decoded along with any problems found in them.
+func LintManifest(filename string, raw []byte) (Manifest, []Problem) {
+	objects := Manifest{}
+	problems := []Problem{}
+
+	docs, _ := splitDocuments(string(raw))
+	for _, doc := range docs {
+		obj, err := NewObject([]byte(doc.Text))
+		if err != nil {
+			problems = append(problems, Problem{File: filename, Line: doc.Line, Rule: RuleDecode, Message: err.Error()})
+			continue
+		}
+		obj.Line = doc.Line
+		objects = append(objects, obj)
+
+		switch obj.Kind {
+		case "DecryptedSecret":
+			problems = append(problems, Problem{
+				File:    filename,
+				Line:    obj.Line,
+				Rule:    RuleDecryptedSecret,
+				Object:  ObjectName(obj),
+				Message: "DecryptedSecret must never be committed, run ridectl edit to encrypt it",
+			})
+		case "EncryptedSecret":
+			for _, loc := range obj.KeyLocs {
+				if IsPayload(obj.Data[loc.Key]) {
+					continue
+				}
+				problems = append(problems, Problem{
+					File:    filename,
+					Line:    obj.KeyLine(loc.Key),
+					Rule:    RuleNotEncrypted,
+					Object:  ObjectName(obj),
+					Key:     loc.Key,
+					Message: "value is not in crypto format",
+				})
+			}
+		}
+	}
+	return objects, problems
+}
+
+// ObjectName returns the namespace/name of an object.
+func ObjectName(obj *Object) string {
+	return fmt.Sprintf("%s/%s", obj.Meta.GetNamespace(), obj.Meta.GetName())
+}
+
+// KeyLine returns the line of a data key in the manifest file, or the line
+// of the object if it isn't found.
+func (o *Object) KeyLine(key string) int {
+	for _, loc := range o.KeyLocs {
+		if loc.Key == key {
+			return o.Line + loc.Value.Line - 1
+		}
+	}
+	return o.Line
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edit
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/nacl/secretbox"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+
+The local key provider stands in for KMS in tests and air-gapped use. Master
+keys are read from a YAML file mapping key IDs (or aliases) to base64 encoded
+32 byte keys:
+
+  alias/sandbox: 0Uf3k6V8m2cE...
+  alias/microservices_dev: kq9s1RZ2bYw...
+
+A new key can be made with `head -c 32 /dev/urandom | base64`. Data keys are
+sealed with secretbox under the master key, and the sealed data key records
+which master key was used.
+
+*/
+
+type localKeyProvider struct {
+	keys map[string]*[32]byte
+}
+
+// localCipherDataKey is the encrypted data key format for the local provider.
+type localCipherDataKey struct {
+	KeyId   string `json:"keyId"`
+	Nonce   []byte `json:"nonce"`
+	Message []byte `json:"message"`
+}
+
+// NewLocalKeyProvider returns a KeyProvider using the master keys in a local file.
+func NewLocalKeyProvider(keysPath string) (KeyProvider, error) {
+	raw, err := os.ReadFile(keysPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading local keys file %s", keysPath)
+	}
+	encodedKeys := map[string]string{}
+	err = yaml.Unmarshal(raw, &encodedKeys)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error decoding local keys file %s", keysPath)
+	}
+
+	p := &localKeyProvider{keys: map[string]*[32]byte{}}
+	for keyId, encodedKey := range encodedKeys {
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error base64 decoding local key %s", keyId)
+		}
+		if len(key) != 32 {
+			return nil, errors.Errorf("local key %s must be 32 bytes, got %d", keyId, len(key))
+		}
+		p.keys[keyId] = &[32]byte{}
+		copy(p.keys[keyId][:], key)
+	}
+	return p, nil
+}
+
+func (p *localKeyProvider) masterKey(keyId string) (*[32]byte, error) {
+	key, ok := p.keys[keyId]
+	if !ok {
+		return nil, errors.Errorf("local key %s not found", keyId)
+	}
+	return key, nil
+}
+
+func (p *localKeyProvider) GenerateDataKey(keyId string) ([]byte, []byte, error) {
+	masterKey, err := p.masterKey(keyId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, errors.Wrap(err, "error generating data key")
+	}
+	nonce := &[nonceLength]byte{}
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, nil, errors.Wrap(err, "error generating nonce")
+	}
+
+	ciphertext, err := json.Marshal(&localCipherDataKey{
+		KeyId:   keyId,
+		Nonce:   nonce[:],
+		Message: secretbox.Seal(nil, plaintext, nonce, masterKey),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintext, ciphertext, nil
+}
+
+func (p *localKeyProvider) Decrypt(ciphertext []byte) ([]byte, string, error) {
+	c := &localCipherDataKey{}
+	err := json.Unmarshal(ciphertext, c)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error decoding local data key")
+	}
+	if len(c.Nonce) != nonceLength {
+		return nil, "", errors.New("invalid nonce in local data key")
+	}
+	masterKey, err := p.masterKey(c.KeyId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nonce := &[nonceLength]byte{}
+	copy(nonce[:], c.Nonce)
+	plaintext, ok := secretbox.Open(nil, c.Message, nonce, masterKey)
+	if !ok {
+		return nil, "", errors.Errorf("error decrypting data key with local key %s", c.KeyId)
+	}
+	return plaintext, c.KeyId, nil
+}
+
+func (p *localKeyProvider) ListAliases(keyId string) ([]string, error) {
+	// Local keys are always referred to by name.
+	return []string{keyId}, nil
+}
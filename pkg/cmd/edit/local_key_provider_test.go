@@ -0,0 +1,383 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// Two throwaway 32 byte keys.
+const testKeys = `alias/sandbox: MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=
+alias/other: ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=
+`
+
+func newTestKeyProvider(t *testing.T) KeyProvider {
+	t.Helper()
+	keysPath := filepath.Join(t.TempDir(), "keys.yml")
+	err := os.WriteFile(keysPath, []byte(testKeys), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyProvider, err := NewLocalKeyProvider(keysPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyProvider
+}
+
+func withEnvelope(t *testing.T, version string) {
+	t.Helper()
+	orig := WriteEnvelope
+	err := SetEnvelope(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { WriteEnvelope = orig })
+}
+
+// encryptManifest encrypts the only secret in a manifest and returns the
+// serialized result.
+func encryptManifest(t *testing.T, keyProvider KeyProvider, text string, keyId string, forceKeyId bool, reEncrypt bool) string {
+	t.Helper()
+	manifest := parseManifest(t, text)
+	err := manifest.Encrypt(keyProvider, keyId, forceKeyId, reEncrypt)
+	if err != nil {
+		t.Fatalf("error encrypting: %v", err)
+	}
+	return serializeManifest(t, manifest)
+}
+
+// decryptManifest parses and decrypts a serialized manifest, returning its
+// only secret.
+func decryptManifest(t *testing.T, keyProvider KeyProvider, text string) (*Object, error) {
+	t.Helper()
+	manifest := parseManifest(t, text)
+	err := manifest.Decrypt(keyProvider, false)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.FindSecret("")
+}
+
+var testData = map[string]string{
+	"KEY":   "value",
+	"EMPTY": "",
+	"MULTI": "line one\nline two\n",
+	"QUOTE": "it's \"quoted\" # not a comment",
+}
+
+func TestLocalKeyProviderRoundTrip(t *testing.T) {
+	keyProvider := newTestKeyProvider(t)
+	for _, version := range []string{EnvelopeV1, EnvelopeV2} {
+		t.Run(version, func(t *testing.T) {
+			withEnvelope(t, version)
+			manifest := parseManifest(t, secretHeader+"data:\n  KEY: old\n")
+			obj, err := manifest.FindSecret("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj.Data = map[string]string{}
+			for key, value := range testData {
+				obj.Data[key] = value
+			}
+			obj.AfterDec.Data = obj.Data
+			err = manifest.Encrypt(keyProvider, "alias/sandbox", false, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encrypted := serializeManifest(t, manifest)
+			if !strings.Contains(encrypted, "kind: EncryptedSecret") {
+				t.Errorf("kind not rewritten:\n%s", encrypted)
+			}
+			if strings.Contains(encrypted, "line one") || strings.Contains(encrypted, "KEY: value") {
+				t.Errorf("plaintext in encrypted manifest:\n%s", encrypted)
+			}
+
+			decrypted, err := decryptManifest(t, keyProvider, encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decrypted.Data, testData) {
+				t.Errorf("decrypted %v, want %v", decrypted.Data, testData)
+			}
+			if decrypted.KeyId != "alias/sandbox" {
+				t.Errorf("decrypted with %s, want alias/sandbox", decrypted.KeyId)
+			}
+		})
+	}
+}
+
+func TestLocalKeyProviderKeepsUnchangedValues(t *testing.T) {
+	keyProvider := newTestKeyProvider(t)
+	withEnvelope(t, EnvelopeV2)
+	encrypted := encryptManifest(t, keyProvider, secretHeader+"data:\n  KEY: value\n  OTHER: other\n", "alias/sandbox", false, false)
+
+	obj, err := decryptManifest(t, keyProvider, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origOther := obj.OrigEnc.Data["OTHER"]
+	origKey := obj.OrigEnc.Data["KEY"]
+	obj.AfterDec = obj.OrigDec.DeepCopy()
+	obj.AfterDec.Data["KEY"] = "changed"
+	err = obj.Encrypt(keyProvider, "alias/other", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Data["OTHER"] != origOther {
+		t.Error("unchanged value was re-encrypted")
+	}
+	if obj.Data["KEY"] == origKey {
+		t.Error("changed value was not re-encrypted")
+	}
+	// Without -k the existing key is kept.
+	if EnvelopeKeyId(obj.Data["KEY"]) != "alias/sandbox" {
+		t.Errorf("changed value recorded key %s, want alias/sandbox", EnvelopeKeyId(obj.Data["KEY"]))
+	}
+}
+
+func TestLocalKeyProviderMovedValue(t *testing.T) {
+	keyProvider := newTestKeyProvider(t)
+	withEnvelope(t, EnvelopeV2)
+	encrypted := encryptManifest(t, keyProvider, secretHeader+"data:\n  KEY: value\n  OTHER: other\n", "alias/sandbox", false, false)
+	obj, err := decryptManifest(t, keyProvider, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"other key", strings.Replace(encrypted, "OTHER: "+obj.OrigEnc.Data["OTHER"], "OTHER: "+obj.OrigEnc.Data["KEY"], 1)},
+		{"other secret", strings.Replace(encrypted, "name: test", "name: copy", 1)},
+		{"other namespace", strings.Replace(encrypted, "namespace: summon-test-dev", "namespace: summon-test-prod", 1)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.text == encrypted {
+				t.Fatal("manifest was not changed")
+			}
+			_, err := decryptManifest(t, keyProvider, test.text)
+			if errors.Cause(err) != ErrTampered {
+				t.Errorf("got error %v, want %v", err, ErrTampered)
+			}
+		})
+	}
+}
+
+func TestLocalKeyProviderRenamedSecret(t *testing.T) {
+	keyProvider := newTestKeyProvider(t)
+	withEnvelope(t, EnvelopeV2)
+	encrypted := encryptManifest(t, keyProvider, secretHeader+"data:\n  KEY: value\n", "alias/sandbox", false, false)
+
+	tests := []struct {
+		name      string
+		namespace string
+		secret    string
+	}{
+		{"other secret", "summon-test-dev", "copy"},
+		{"other namespace", "summon-test-prod", "test"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Like edit, the data is unchanged but the metadata is.
+			obj, err := decryptManifest(t, keyProvider, encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj.AfterDec = obj.OrigDec.DeepCopy()
+			obj.AfterDec.Namespace = test.namespace
+			obj.AfterDec.Name = test.secret
+			err = obj.Encrypt(keyProvider, "alias/sandbox", false, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.Data["KEY"] == obj.OrigEnc.Data["KEY"] {
+				t.Fatal("value of the renamed secret was not re-encrypted")
+			}
+
+			renamed := strings.Replace(strings.Replace(encrypted, "name: test", "name: "+test.secret, 1), "namespace: summon-test-dev", "namespace: "+test.namespace, 1)
+			renamed = strings.Replace(renamed, obj.OrigEnc.Data["KEY"], obj.Data["KEY"], 1)
+			decrypted, err := decryptManifest(t, keyProvider, renamed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decrypted.Data["KEY"] != "value" {
+				t.Errorf("decrypted %q, want value", decrypted.Data["KEY"])
+			}
+		})
+	}
+}
+
+func TestEditRenamedSecret(t *testing.T) {
+	keyProvider := newTestKeyProvider(t)
+	withEnvelope(t, EnvelopeV2)
+	encrypted := encryptManifest(t, keyProvider, secretHeader+"data:\n  KEY: value\n", "alias/sandbox", false, false)
+	inManifest := parseManifest(t, encrypted)
+	err := inManifest.Decrypt(keyProvider, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The edited manifest, with the secret renamed.
+	afterManifest := parseManifest(t, strings.Replace(serializeManifest(t, inManifest), "name: test", "name: copy", 1))
+	err = afterManifest.CorrelateWith(inManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = afterManifest.Encrypt(keyProvider, "alias/sandbox", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := decryptManifest(t, keyProvider, serializeManifest(t, afterManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Meta.GetName() != "copy" || obj.Data["KEY"] != "value" {
+		t.Errorf("decrypted %s with %v, want copy with KEY: value", obj.Meta.GetName(), obj.Data)
+	}
+}
+
+func TestLocalKeyProviderUnknownKey(t *testing.T) {
+	keyProvider := newTestKeyProvider(t)
+	manifest := parseManifest(t, secretHeader+"data:\n  KEY: value\n")
+	err := manifest.Encrypt(keyProvider, "alias/missing", false, false)
+	if err == nil || !strings.Contains(err.Error(), "local key alias/missing not found") {
+		t.Errorf("got error %v, want local key alias/missing not found", err)
+	}
+}
+
+func TestLocalKeyProviderForcedKey(t *testing.T) {
+	keyProvider := newTestKeyProvider(t)
+	withEnvelope(t, EnvelopeV2)
+	encrypted := encryptManifest(t, keyProvider, secretHeader+"data:\n  KEY: value\n  OTHER: other\n", "alias/sandbox", false, false)
+	obj, err := decryptManifest(t, keyProvider, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As with -k, every value moves to the new key, not only the changed one.
+	obj.AfterDec = obj.OrigDec.DeepCopy()
+	obj.AfterDec.Data["KEY"] = "changed"
+	err = obj.Encrypt(keyProvider, "alias/other", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range obj.Data {
+		if EnvelopeKeyId(value) != "alias/other" {
+			t.Errorf("%s recorded key %s, want alias/other", key, EnvelopeKeyId(value))
+		}
+	}
+	out := &strings.Builder{}
+	err = obj.Serialize(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := decryptManifest(t, keyProvider, out.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reparsed.KeyIds, []string{"alias/other"}) {
+		t.Errorf("decrypted with %v, want only alias/other", reparsed.KeyIds)
+	}
+	if reparsed.Data["KEY"] != "changed" || reparsed.Data["OTHER"] != "other" {
+		t.Errorf("decrypted %v", reparsed.Data)
+	}
+}
+
+func TestCheckBound(t *testing.T) {
+	keyProvider := newTestKeyProvider(t)
+	withEnvelope(t, EnvelopeV2)
+	bound := encryptManifest(t, keyProvider, secretHeader+"data:\n  KEY: value\n  OTHER: other\n", "alias/sandbox", false, false)
+	err := parseManifest(t, bound).CheckBound()
+	if err != nil {
+		t.Errorf("all values are bound: %v", err)
+	}
+
+	// Downgrade OTHER to a v1 value, which still decrypts.
+	withEnvelope(t, EnvelopeV1)
+	unbound := encryptManifest(t, keyProvider, secretHeader+"data:\n  OTHER: swapped\n", "alias/sandbox", false, false)
+	obj, err := parseManifest(t, bound).FindSecret("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1Obj, err := parseManifest(t, unbound).FindSecret("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed := strings.Replace(bound, obj.OrigEnc.Data["OTHER"], v1Obj.OrigEnc.Data["OTHER"], 1)
+	manifest := parseManifest(t, mixed)
+	err = manifest.CheckBound()
+	if err == nil || !strings.Contains(err.Error(), "not bound to it: OTHER.") {
+		t.Errorf("got error %v, want OTHER not bound", err)
+	}
+	decrypted, err := decryptManifest(t, keyProvider, mixed)
+	if err != nil || decrypted.Data["OTHER"] != "swapped" {
+		t.Errorf("v1 value should decrypt outside strict mode, got %v %v", decrypted, err)
+	}
+}
+
+// arnKeyProvider returns key ARNs when decrypting, like KMS does.
+type arnKeyProvider struct {
+	KeyProvider
+}
+
+const testArnPrefix = "arn:aws:kms:us-west-1:000000000000:key/"
+
+func (p arnKeyProvider) GenerateDataKey(keyId string) ([]byte, []byte, error) {
+	return p.KeyProvider.GenerateDataKey(strings.Replace(keyId, testArnPrefix, "alias/", 1))
+}
+
+func (p arnKeyProvider) Decrypt(ciphertext []byte) ([]byte, string, error) {
+	plaintext, keyId, err := p.KeyProvider.Decrypt(ciphertext)
+	return plaintext, strings.Replace(keyId, "alias/", testArnPrefix, 1), err
+}
+
+func (p arnKeyProvider) ListAliases(keyId string) ([]string, error) {
+	return []string{strings.Replace(keyId, testArnPrefix, "alias/", 1)}, nil
+}
+
+func TestRecordedKeyIdIsAlias(t *testing.T) {
+	keyProvider := arnKeyProvider{newTestKeyProvider(t)}
+	withEnvelope(t, EnvelopeV2)
+	encrypted := encryptManifest(t, keyProvider, secretHeader+"data:\n  KEY: value\n", "alias/sandbox", false, false)
+	obj, err := decryptManifest(t, keyProvider, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.KeyId != testArnPrefix+"sandbox" {
+		t.Fatalf("decrypted with %s, want the key ARN", obj.KeyId)
+	}
+
+	// The existing key is reused, but recorded by its alias.
+	obj.AfterDec = obj.OrigDec.DeepCopy()
+	obj.AfterDec.Data["KEY"] = "changed"
+	err = obj.Encrypt(keyProvider, "alias/other", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyId := EnvelopeKeyId(obj.Data["KEY"]); keyId != "alias/sandbox" {
+		t.Errorf("recorded %s, want alias/sandbox", keyId)
+	}
+}
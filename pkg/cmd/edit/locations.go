@@ -0,0 +1,605 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edit
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	yaml "go.yaml.in/yaml/v3"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+// The YAML node tree tells us where each value starts, but not where it ends.
+// The helpers in here work out the byte span of a scalar from its start
+// position and style, so Serialize can splice new values into the original
+// text without touching comments, whitespace, key ordering, etc.
+
+// parseLocations parses the raw text into a YAML node tree and records the
+// location of the kind value and of every value in the data mapping.
+func (o *Object) parseLocations() error {
+	doc := &yaml.Node{}
+	err := yaml.Unmarshal(o.Raw, doc)
+	if err != nil {
+		return errors.Wrap(err, "error parsing YAML")
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return errors.New("expected a YAML mapping at the top level")
+	}
+	root := doc.Content[0]
+	lines := newLineIndex(o.Raw)
+
+	kindKey, kindValue := mappingEntry(root, "kind")
+	if kindValue == nil {
+		return errors.New("unable to find kind")
+	}
+	o.KindLoc, err = newKeysLocation(o.Raw, lines, kindKey, kindValue, false, 0)
+	if err != nil {
+		return errors.Wrap(err, "error locating kind")
+	}
+
+	locs := []KeysLocation{}
+	_, data := mappingEntry(root, "data")
+	if data != nil && !isNull(data) {
+		if data.Kind != yaml.MappingNode {
+			return errors.New("data must be a mapping")
+		}
+		flow := data.Style&yaml.FlowStyle != 0
+		for i := 0; i+1 < len(data.Content); i += 2 {
+			key, value := data.Content[i], data.Content[i+1]
+			loc, err := newKeysLocation(o.Raw, lines, key, value, flow, key.Column-1)
+			if err != nil {
+				return errors.Wrapf(err, "error locating value for %s", key.Value)
+			}
+			locs = append(locs, loc)
+		}
+	}
+
+	// A safety check, every decoded key should have exactly one location.
+	if len(o.Data) != len(locs) {
+		return errors.Errorf("key count mismatch, decoded %d keys but found %d in YAML", len(o.Data), len(locs))
+	}
+
+	o.Doc = doc
+	o.KeyLocs = locs
+	return nil
+}
+
+func mappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+// lineIndex holds the byte offset of the start of every line.
+type lineIndex []int
+
+func newLineIndex(raw []byte) lineIndex {
+	lines := lineIndex{0}
+	for i, b := range raw {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// offset converts a 1-based line and column, as reported by the YAML parser,
+// to a byte offset. Columns count characters rather than bytes.
+func (l lineIndex) offset(raw []byte, line int, column int) (int, error) {
+	if line < 1 || line > len(l) {
+		return 0, errors.Errorf("line %d out of range", line)
+	}
+	pos := l[line-1]
+	for i := 1; i < column; i++ {
+		if pos >= len(raw) || raw[pos] == '\n' {
+			return 0, errors.Errorf("column %d out of range on line %d", column, line)
+		}
+		_, size := utf8.DecodeRune(raw[pos:])
+		pos += size
+	}
+	return pos, nil
+}
+
+func newKeysLocation(raw []byte, lines lineIndex, key *yaml.Node, value *yaml.Node, flow bool, indentStep int) (KeysLocation, error) {
+	loc := KeysLocation{Key: key.Value, Value: value, Flow: flow, Newline: newline(raw)}
+	if value.Kind != yaml.ScalarNode {
+		return loc, errors.New("only scalar values are supported")
+	}
+	if value.Anchor != "" || value.Alias != nil {
+		return loc, errors.New("anchors and aliases are not supported")
+	}
+
+	keyStart, err := lines.offset(raw, key.Line, key.Column)
+	if err != nil {
+		return loc, err
+	}
+	start, err := lines.offset(raw, value.Line, value.Column)
+	if err != nil {
+		return loc, err
+	}
+	loc.KeyStart = keyStart
+	parentIndent := key.Column - 1
+	loc.Start = start
+	loc.Indent = parentIndent + indentStep
+	if indentStep == 0 {
+		loc.Indent = parentIndent + 2
+	}
+
+	switch {
+	case value.Style&yaml.DoubleQuotedStyle != 0:
+		loc.End, err = doubleQuotedEnd(raw, start)
+	case value.Style&yaml.SingleQuotedStyle != 0:
+		loc.End, err = singleQuotedEnd(raw, start)
+	case value.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		loc.End, loc.Comment, loc.Indent = blockEnd(raw, start, parentIndent, loc.Indent)
+		loc.CommentEnd = loc.End
+		return loc, nil
+	case flow:
+		loc.End = flowPlainEnd(raw, start)
+	default:
+		loc.End = plainEnd(raw, start, parentIndent)
+	}
+	if err != nil {
+		return loc, err
+	}
+
+	// Track a comment following the value on the same line, it has to move
+	// onto the header line if the value is rewritten as a block.
+	loc.CommentEnd = loc.End
+	if !flow {
+		rest := strings.TrimRight(string(raw[loc.End:lineEnd(raw, loc.End)]), " \t\r")
+		if strings.HasPrefix(strings.TrimLeft(rest, " \t"), "#") {
+			loc.Comment = rest
+			loc.CommentEnd = loc.End + len(rest)
+		}
+	}
+	return loc, nil
+}
+
+func doubleQuotedEnd(raw []byte, start int) (int, error) {
+	for i := start + 1; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, errors.New("unterminated double quoted value")
+}
+
+func singleQuotedEnd(raw []byte, start int) (int, error) {
+	for i := start + 1; i < len(raw); i++ {
+		if raw[i] != '\'' {
+			continue
+		}
+		if i+1 < len(raw) && raw[i+1] == '\'' {
+			// An escaped quote.
+			i++
+			continue
+		}
+		return i + 1, nil
+	}
+	return 0, errors.New("unterminated single quoted value")
+}
+
+// lineEnd returns the offset of the newline ending the line containing pos, or
+// the end of the text.
+func lineEnd(raw []byte, pos int) int {
+	end := bytes.IndexByte(raw[pos:], '\n')
+	if end == -1 {
+		return len(raw)
+	}
+	return pos + end
+}
+
+// contentEnd returns the offset of the line break ending the line containing
+// pos, before any CR, or the end of the text.
+func contentEnd(raw []byte, pos int) int {
+	end := lineEnd(raw, pos)
+	if end > pos && raw[end-1] == '\r' {
+		end--
+	}
+	return end
+}
+
+// newline returns the line ending a text uses, going by its first line.
+func newline(raw []byte) string {
+	end := bytes.IndexByte(raw, '\n')
+	if end > 0 && raw[end-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// stripComment trims a trailing comment and whitespace from a line segment
+// holding a plain value.
+func stripComment(raw []byte, start int, end int) int {
+	for i := start; i < end; i++ {
+		if raw[i] == '#' && (i == start || raw[i-1] == ' ' || raw[i-1] == '\t') {
+			end = i
+			break
+		}
+	}
+	for end > start && isSpace(raw[end-1]) {
+		end--
+	}
+	return end
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r'
+}
+
+func indentation(line []byte) (int, bool) {
+	n := 0
+	for n < len(line) && line[n] == ' ' {
+		n++
+	}
+	blank := len(bytes.TrimSpace(line[n:])) == 0
+	return n, blank
+}
+
+func plainEnd(raw []byte, start int, parentIndent int) int {
+	end := stripComment(raw, start, lineEnd(raw, start))
+	// Plain values can continue on following lines as long as they are
+	// indented further than their key.
+	pos := lineEnd(raw, start)
+	for pos < len(raw) {
+		next := lineEnd(raw, pos+1)
+		line := raw[pos+1 : next]
+		indent, blank := indentation(line)
+		if !blank {
+			if indent <= parentIndent || line[indent] == '#' {
+				break
+			}
+			end = stripComment(raw, pos+1, next)
+		}
+		pos = next
+	}
+	return end
+}
+
+func flowPlainEnd(raw []byte, start int) int {
+	end := lineEnd(raw, start)
+	for i := start; i < end; i++ {
+		if c := raw[i]; c == ',' || c == '}' || c == ']' {
+			end = i
+			break
+		}
+	}
+	return stripComment(raw, start, end)
+}
+
+// blockEnd finds the end of a literal or folded block scalar. It also returns
+// any comment on the header line and the indentation used by the content.
+func blockEnd(raw []byte, start int, parentIndent int, defaultIndent int) (int, string, int) {
+	headerEnd := lineEnd(raw, start)
+	comment := ""
+	if trimmed := stripComment(raw, start, headerEnd); trimmed != headerEnd {
+		comment = strings.TrimRight(string(raw[trimmed:headerEnd]), " \t\r")
+	}
+	end := contentEnd(raw, start)
+	contentIndent := 0
+	pos := headerEnd
+	for pos < len(raw) {
+		next := lineEnd(raw, pos+1)
+		indent, blank := indentation(raw[pos+1 : next])
+		if !blank {
+			if indent <= parentIndent {
+				break
+			}
+			if contentIndent == 0 {
+				contentIndent = indent
+			}
+			end = contentEnd(raw, pos+1)
+		}
+		pos = next
+	}
+	if contentIndent == 0 {
+		contentIndent = defaultIndent
+	}
+	return end, comment, contentIndent
+}
+
+// textEdit replaces the raw text between Start and End.
+type textEdit struct {
+	TextLocation
+	Text string
+}
+
+// replace returns the edit to write a new value at the location. Unchanged
+// values keep their original text.
+func (loc KeysLocation) replace(value string) (textEdit, bool) {
+	if value == loc.Value.Value || (value == "" && isNull(loc.Value)) {
+		return textEdit{}, false
+	}
+	text, end := loc.format(value)
+	if loc.Start == loc.End {
+		// An empty value directly after the key's colon.
+		text = " " + text
+	}
+	return textEdit{TextLocation: TextLocation{Start: loc.Start, End: end}, Text: text}, true
+}
+
+// remove returns the edit to remove the lines holding the key and value at
+// the location.
+func (loc KeysLocation) remove(raw []byte) textEdit {
+	start := bytes.LastIndexByte(raw[:loc.KeyStart], '\n') + 1
+	end := lineEnd(raw, loc.CommentEnd)
+	if end < len(raw) {
+		end++
+	}
+	return textEdit{TextLocation: TextLocation{Start: start, End: end}}
+}
+
+// format renders a value to be written over the location, returning the
+// text and the offset it replaces up to. Values are written as plain scalars
+// where YAML would read them back as the same string, as literal blocks when
+// they span lines, and double quoted otherwise.
+func (loc KeysLocation) format(value string) (string, int) {
+	if !loc.Flow && strings.Contains(value, "\n") && isLiteralSafe(value) {
+		var buf strings.Builder
+		lines := strings.Split(value, "\n")
+		chomp := "-"
+		if strings.HasSuffix(value, "\n") {
+			lines = lines[:len(lines)-1]
+			chomp = ""
+			if strings.HasSuffix(value, "\n\n") {
+				chomp = "+"
+			}
+		}
+		buf.WriteString("|")
+		buf.WriteString(chomp)
+		buf.WriteString(loc.Comment)
+		for _, line := range lines {
+			buf.WriteString(loc.Newline)
+			if line != "" {
+				buf.WriteString(strings.Repeat(" ", loc.Indent))
+				buf.WriteString(line)
+			}
+		}
+		return buf.String(), loc.CommentEnd
+	}
+
+	text := formatScalar(value, loc.Flow)
+	if loc.CommentEnd == loc.End {
+		// Either there is no comment, or it was inside a block header we are replacing.
+		text += loc.Comment
+	}
+	return text, loc.End
+}
+
+func formatScalar(value string, flow bool) string {
+	if isPlainSafe(value, flow) {
+		return value
+	}
+	// Go's quoting escapes are a subset of what YAML double quoted scalars accept.
+	return strconv.Quote(value)
+}
+
+// dataEdits returns the edits to make to the data mapping.
+func (o *Object) dataEdits() ([]textEdit, error) {
+	edits := []textEdit{}
+	existing := map[string]bool{}
+	removed := false
+	for _, keyLoc := range o.KeyLocs {
+		existing[keyLoc.Key] = true
+		if _, ok := o.Data[keyLoc.Key]; !ok {
+			removed = true
+		}
+	}
+	newKeys := []string{}
+	for key := range o.Data {
+		if !existing[key] {
+			newKeys = append(newKeys, key)
+		}
+	}
+	sort.Strings(newKeys)
+
+	if len(o.KeyLocs) > 0 && o.KeyLocs[0].Flow && (removed || len(newKeys) > 0) {
+		// Keys are being added or removed from a flow mapping, rewrite the
+		// entries in one go rather than juggling the commas.
+		return []textEdit{o.rewriteFlowData(newKeys)}, nil
+	}
+
+	for _, keyLoc := range o.KeyLocs {
+		newValue, ok := o.Data[keyLoc.Key]
+		if !ok {
+			edits = append(edits, keyLoc.remove(o.Raw))
+			continue
+		}
+		if edit, ok := keyLoc.replace(newValue); ok {
+			edits = append(edits, edit)
+		}
+	}
+	insertEdits, err := o.insertKeys(newKeys)
+	if err != nil {
+		return nil, err
+	}
+	return append(edits, insertEdits...), nil
+}
+
+func (o *Object) rewriteFlowData(newKeys []string) textEdit {
+	entries := []string{}
+	for _, keyLoc := range o.KeyLocs {
+		newValue, ok := o.Data[keyLoc.Key]
+		if !ok {
+			continue
+		}
+		entry := string(o.Raw[keyLoc.KeyStart:keyLoc.Start])
+		if edit, ok := keyLoc.replace(newValue); ok {
+			entry += edit.Text
+		} else {
+			entry += string(o.Raw[keyLoc.Start:keyLoc.End])
+		}
+		entries = append(entries, entry)
+	}
+	for _, key := range newKeys {
+		entries = append(entries, formatScalar(key, true)+": "+formatScalar(o.Data[key], true))
+	}
+	last := o.KeyLocs[len(o.KeyLocs)-1]
+	return textEdit{TextLocation: TextLocation{Start: o.KeyLocs[0].KeyStart, End: last.End}, Text: strings.Join(entries, ", ")}
+}
+
+// insertKeys returns the edits to add new keys to the data mapping, after
+// any existing keys.
+func (o *Object) insertKeys(keys []string) ([]textEdit, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	// New lines use the same line ending as the rest of the document.
+	nl := newline(o.Raw)
+	blockEntries := func(keyIndent int) string {
+		var buf strings.Builder
+		loc := KeysLocation{Indent: keyIndent * 2, Newline: nl}
+		if keyIndent == 0 {
+			loc.Indent = 2
+		}
+		for _, key := range keys {
+			buf.WriteString(nl)
+			buf.WriteString(strings.Repeat(" ", keyIndent))
+			buf.WriteString(formatScalar(key, false))
+			buf.WriteString(": ")
+			value, _ := loc.format(o.Data[key])
+			buf.WriteString(value)
+		}
+		return buf.String()
+	}
+
+	if len(o.KeyLocs) > 0 {
+		// After the last key being kept, or in place of the first if all of
+		// them are being removed.
+		first := o.KeyLocs[0]
+		lineStart := bytes.LastIndexByte(o.Raw[:first.KeyStart], '\n') + 1
+		for i := len(o.KeyLocs) - 1; i >= 0; i-- {
+			keyLoc := o.KeyLocs[i]
+			if _, ok := o.Data[keyLoc.Key]; ok {
+				pos := contentEnd(o.Raw, keyLoc.CommentEnd)
+				return []textEdit{{TextLocation: TextLocation{Start: pos, End: pos}, Text: blockEntries(first.KeyStart - lineStart)}}, nil
+			}
+		}
+		return []textEdit{{TextLocation: TextLocation{Start: lineStart, End: lineStart}, Text: blockEntries(first.KeyStart - lineStart)[len(nl):] + nl}}, nil
+	}
+
+	_, data := mappingEntry(o.Doc.Content[0], "data")
+	lines := newLineIndex(o.Raw)
+	switch {
+	case data == nil:
+		// No data at all, add it at the end.
+		pos := len(o.Raw)
+		text := "data:" + blockEntries(2) + nl
+		if pos > 0 && o.Raw[pos-1] != '\n' {
+			text = nl + text
+		}
+		return []textEdit{{TextLocation: TextLocation{Start: pos, End: pos}, Text: text}}, nil
+	case isNull(data):
+		// Drop any explicit null and add the keys on the following lines.
+		start, err := lines.offset(o.Raw, data.Line, data.Column)
+		if err != nil {
+			return nil, err
+		}
+		end := stripComment(o.Raw, start, lineEnd(o.Raw, start))
+		// Along with the space after it, or before it if nothing follows.
+		if len(bytes.TrimSpace(o.Raw[end:lineEnd(o.Raw, start)])) == 0 {
+			for start > 0 && (o.Raw[start-1] == ' ' || o.Raw[start-1] == '\t') {
+				start--
+			}
+		} else {
+			for o.Raw[end] == ' ' || o.Raw[end] == '\t' {
+				end++
+			}
+		}
+		pos := contentEnd(o.Raw, start)
+		return []textEdit{
+			{TextLocation: TextLocation{Start: start, End: end}},
+			{TextLocation: TextLocation{Start: pos, End: pos}, Text: blockEntries(2)},
+		}, nil
+	case data.Kind == yaml.MappingNode && data.Style&yaml.FlowStyle != 0:
+		// An empty flow mapping, add the keys inside the braces.
+		start, err := lines.offset(o.Raw, data.Line, data.Column)
+		if err != nil {
+			return nil, err
+		}
+		pos := bytes.IndexByte(o.Raw[start:], '}')
+		if pos == -1 {
+			return nil, errors.New("unable to find end of data")
+		}
+		entries := []string{}
+		for _, key := range keys {
+			entries = append(entries, formatScalar(key, true)+": "+formatScalar(o.Data[key], true))
+		}
+		return []textEdit{{TextLocation: TextLocation{Start: start + pos, End: start + pos}, Text: strings.Join(entries, ", ")}}, nil
+	}
+	return nil, errors.New("unable to add keys to data")
+}
+
+// isPlainSafe checks if YAML would read back value unquoted as the same string.
+func isPlainSafe(value string, flow bool) bool {
+	if value == "" || strings.ContainsAny(value, "\n\r\t") || strings.TrimSpace(value) != value {
+		return false
+	}
+	if flow && strings.ContainsAny(value, ",[]{}") {
+		return false
+	}
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(value), node); err != nil {
+		return false
+	}
+	if len(node.Content) != 1 {
+		return false
+	}
+	scalar := node.Content[0]
+	if scalar.Kind != yaml.ScalarNode || scalar.Style != 0 || scalar.Tag != "!!str" || scalar.Value != value {
+		return false
+	}
+	// Kubernetes decodes YAML 1.1 where things like y and off are booleans,
+	// so check that reading too.
+	var decoded interface{}
+	if err := yamlv2.Unmarshal([]byte(value), &decoded); err != nil {
+		return false
+	}
+	decodedString, ok := decoded.(string)
+	return ok && decodedString == value
+}
+
+// isLiteralSafe checks if value can be written as a literal block without an
+// indentation indicator or escapes.
+func isLiteralSafe(value string) bool {
+	if strings.HasPrefix(value, " ") || strings.HasPrefix(value, "\n") {
+		return false
+	}
+	for _, r := range value {
+		if r == '\r' || (r != '\n' && r != '\t' && !unicode.IsPrint(r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -18,8 +18,8 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/pkg/errors"
 )
 
@@ -28,7 +28,8 @@ var splitRegexp *regexp.Regexp
 
 func init() {
 	emptyRegexp = regexp.MustCompile(`(?m)\A(^(\s*#.*|\s*)$\s*)*\z`)
-	splitRegexp = regexp.MustCompile(`(?m)^---$(\n)?`)
+	// A document separator, optionally followed by a comment or a CR.
+	splitRegexp = regexp.MustCompile(`(?m)^---([ \t]+#[^\n]*|[ \t]*)\r?$(\n)?`)
 }
 
 func NewManifest(in io.Reader) (Manifest, error) {
@@ -42,32 +43,70 @@ func NewManifest(in io.Reader) (Manifest, error) {
 
 	objects := []*Object{}
 
-	for _, chunk := range splitRegexp.Split(buf.String(), -1) {
-		if emptyRegexp.MatchString(chunk) {
-			continue
-		}
-		obj, err := NewObject([]byte(chunk))
+	docs, after := splitDocuments(buf.String())
+	for _, doc := range docs {
+		obj, err := NewObject([]byte(doc.Text))
 		if err != nil {
 			return nil, errors.Wrap(err, "error decoding object")
 		}
+		obj.Line = doc.Line
+		obj.Before = []byte(doc.Before)
 		objects = append(objects, obj)
 	}
+	if len(objects) > 0 {
+		objects[len(objects)-1].After = []byte(after)
+	}
 	return objects, nil
 }
 
-func (m Manifest) Decrypt(kmsService *kms.Client, recrypt bool) error {
-	for _, obj := range m {
-		err := obj.Decrypt(kmsService, recrypt)
+// document is a non-empty YAML document in a manifest.
+type document struct {
+	Text string
+	// The line the document starts on.
+	Line int
+	// The text between the previous document and this one: separators, and
+	// any documents with only comments.
+	Before string
+}
+
+// splitDocuments splits a manifest into its non-empty YAML documents. It also
+// returns the text after the last one, so the manifest can be written back
+// with the same separators.
+func splitDocuments(text string) ([]document, string) {
+	docs := []document{}
+	start := 0
+	line := 1
+	before := ""
+	for _, sep := range append(splitRegexp.FindAllStringIndex(text, -1), []int{len(text), len(text)}) {
+		chunk := text[start:sep[0]]
+		if emptyRegexp.MatchString(chunk) {
+			before += chunk
+		} else {
+			docs = append(docs, document{Text: chunk, Line: line, Before: before})
+			before = ""
+		}
+		before += text[sep[0]:sep[1]]
+		line += strings.Count(text[start:sep[1]], "\n")
+		start = sep[1]
+	}
+	return docs, before
+}
+
+// Decrypt decrypts the objects in parallel, see ForEachParallel.
+func (m Manifest) Decrypt(keyProvider KeyProvider, recrypt bool) error {
+	return ForEachParallel(len(m), func(i int) error {
+		obj := m[i]
+		err := obj.Decrypt(keyProvider, recrypt)
 		if err != nil {
 			return errors.Wrapf(err, "error decrypting %s/%s", obj.Meta.GetNamespace(), obj.Meta.GetName())
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
-func (m Manifest) Encrypt(kmsService *kms.Client, defaultKeyId string, forceKeyId bool, reEncrypt bool) error {
+func (m Manifest) Encrypt(keyProvider KeyProvider, defaultKeyId string, forceKeyId bool, reEncrypt bool) error {
 	for _, obj := range m {
-		err := obj.Encrypt(kmsService, defaultKeyId, forceKeyId, reEncrypt)
+		err := obj.Encrypt(keyProvider, defaultKeyId, forceKeyId, reEncrypt)
 		if err != nil {
 			return errors.Wrapf(err, "error encrypting %s/%s", obj.Meta.GetNamespace(), obj.Meta.GetName())
 		}
@@ -75,21 +114,67 @@ func (m Manifest) Encrypt(kmsService *kms.Client, defaultKeyId string, forceKeyI
 	return nil
 }
 
+// Serialize writes the objects with the separators they were read with, or
+// "---" between objects which were not read from a manifest.
 func (m Manifest) Serialize(out io.Writer) error {
-	first := true
-	for _, obj := range m {
-		if !first {
-			_, _ = out.Write([]byte("---\n"))
+	for i, obj := range m {
+		before := obj.Before
+		if len(before) == 0 && i > 0 {
+			before = []byte("---\n")
+		}
+		_, err := out.Write(before)
+		if err != nil {
+			return err
 		}
-		first = false
-		err := obj.Serialize(out)
+		err = obj.Serialize(out)
 		if err != nil {
 			return errors.Wrapf(err, "error serializing %s/%s", obj.Meta.GetNamespace(), obj.Meta.GetName())
 		}
+		_, err = out.Write(obj.After)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// CheckBound checks every EncryptedSecret value is bound to where it is
+// stored, for strict mode.
+func (m Manifest) CheckBound() error {
+	for _, obj := range m {
+		unbound := obj.UnboundKeys()
+		if len(unbound) > 0 {
+			return errors.Errorf("%s has values which are not bound to it: %s. Check them, then re-encrypt them with ridectl edit --strict=false -r --envelope v2", ObjectName(obj), strings.Join(unbound, ", "))
+		}
+	}
+	return nil
+}
+
+// FindSecret returns the EncryptedSecret or DecryptedSecret with the given
+// name, or the only one in the manifest if name is empty.
+func (m Manifest) FindSecret(name string) (*Object, error) {
+	secrets := []*Object{}
+	names := []string{}
+	for _, obj := range m {
+		if obj.Kind == "" {
+			continue
+		}
+		names = append(names, obj.Meta.GetName())
+		if name == "" || obj.Meta.GetName() == name {
+			secrets = append(secrets, obj)
+		}
+	}
+	switch {
+	case len(secrets) == 1:
+		return secrets[0], nil
+	case len(names) == 0:
+		return nil, errors.New("no secrets found in manifest")
+	case len(secrets) == 0:
+		return nil, errors.Errorf("secret %s not found, found: %s", name, strings.Join(names, ", "))
+	}
+	return nil, errors.Errorf("found multiple secrets: %s", strings.Join(names, ", "))
+}
+
 func (m Manifest) CorrelateWith(origManifest Manifest) error {
 	// Build a map of the input secrets.
 	origByName := map[string]*Object{}
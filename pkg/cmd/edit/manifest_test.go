@@ -0,0 +1,225 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edit
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/scheme"
+
+	secretsv1beta2 "github.com/Ridecell/ridecell-controllers/apis/secrets/v1beta2"
+	hackapis "github.com/Ridecell/ridectl/pkg/apis"
+)
+
+func init() {
+	_ = secretsv1beta2.AddToScheme(scheme.Scheme)
+	_ = hackapis.AddToScheme(scheme.Scheme)
+}
+
+const secretHeader = "apiVersion: secrets.controllers.ridecell.io/v1beta2\nkind: DecryptedSecret\nmetadata:\n  name: test\n  namespace: summon-test-dev\n"
+
+const configMap = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  A: b\n"
+
+func parseManifest(t *testing.T, text string) Manifest {
+	t.Helper()
+	manifest, err := NewManifest(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("error parsing manifest: %v\n%s", err, text)
+	}
+	return manifest
+}
+
+func serializeManifest(t *testing.T, manifest Manifest) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	err := manifest.Serialize(buf)
+	if err != nil {
+		t.Fatalf("error serializing manifest: %v", err)
+	}
+	return buf.String()
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"single", secretHeader + "data:\n  KEY: value\n"},
+		{"separator", secretHeader + "data:\n  KEY: value\n---\n" + configMap},
+		{"leading separator", "---\n" + secretHeader + "data:\n  KEY: value\n"},
+		{"separator comment", secretHeader + "data:\n  KEY: value\n--- # the config\n" + configMap},
+		{"trailing separator", secretHeader + "data:\n  KEY: value\n---\n"},
+		{"no final newline", secretHeader + "data:\n  KEY: value"},
+		{"crlf", strings.ReplaceAll(secretHeader+"data:\n  KEY: value # comment\n---\n"+configMap, "\n", "\r\n")},
+		{"comment only document", secretHeader + "data:\n  KEY: value\n---\n# nothing here yet\n\n---\n" + configMap},
+		{"leading comment document", "# generated\n---\n" + secretHeader + "data:\n  KEY: value\n"},
+		{"literal block", secretHeader + "data:\n  CERT: |\n    line one\n    line two\n  KEY: value\n"},
+		{"folded block", secretHeader + "data:\n  TEXT: >-\n    folded\n    text\n"},
+		{"eight space indent", secretHeader + "data:\n        KEY: value\n        OTHER: |\n                block\n"},
+		{"flow mapping", secretHeader + "data: {KEY: value, OTHER: \"quoted\"}\n"},
+		{"data not last", "apiVersion: secrets.controllers.ridecell.io/v1beta2\nkind: DecryptedSecret\ndata:\n  KEY: value\nmetadata:\n  name: test\n"},
+		{"comments", secretHeader + "# before data\ndata:\n  # before key\n  KEY: value # after value\n  OTHER: 'single' # quoted\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifest := parseManifest(t, test.text)
+			out := serializeManifest(t, manifest)
+			if out != test.text {
+				t.Errorf("round trip changed the manifest\nwant:\n%q\ngot:\n%q", test.text, out)
+			}
+		})
+	}
+}
+
+func TestManifestEdits(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		// The data of the single secret after editing.
+		data map[string]string
+		want string
+	}{
+		{
+			name: "change plain value keeps comment",
+			text: secretHeader + "data:\n  KEY: value # after value\n  OTHER: x\n",
+			data: map[string]string{"KEY": "new", "OTHER": "x"},
+			want: secretHeader + "data:\n  KEY: new # after value\n  OTHER: x\n",
+		},
+		{
+			name: "literal block to single line",
+			text: secretHeader + "data:\n  CERT: |\n    line one\n    line two\n  KEY: value\n",
+			data: map[string]string{"CERT": "one line", "KEY": "value"},
+			want: secretHeader + "data:\n  CERT: one line\n  KEY: value\n",
+		},
+		{
+			name: "folded block changed",
+			text: secretHeader + "data:\n  TEXT: >-\n    folded\n    text\n  KEY: value\n",
+			data: map[string]string{"TEXT": "a\nb", "KEY": "value"},
+			want: secretHeader + "data:\n  TEXT: |-\n    a\n    b\n  KEY: value\n",
+		},
+		{
+			name: "single line to block keeps comment on header",
+			text: secretHeader + "data:\n  CERT: old # the cert\n",
+			data: map[string]string{"CERT": "line one\nline two\n"},
+			want: secretHeader + "data:\n  CERT: | # the cert\n    line one\n    line two\n",
+		},
+		{
+			name: "eight space indent add key",
+			text: secretHeader + "data:\n        KEY: value\n",
+			data: map[string]string{"KEY": "value", "NEW": "line one\nline two"},
+			want: secretHeader + "data:\n        KEY: value\n        NEW: |-\n                line one\n                line two\n",
+		},
+		{
+			name: "flow mapping change",
+			text: secretHeader + "data: {KEY: value, OTHER: x}\n",
+			data: map[string]string{"KEY": "new, value", "OTHER": "x"},
+			want: secretHeader + "data: {KEY: \"new, value\", OTHER: x}\n",
+		},
+		{
+			name: "flow mapping add and remove",
+			text: secretHeader + "data: {KEY: value, OTHER: x}\n",
+			data: map[string]string{"OTHER": "x", "NEW": "z"},
+			want: secretHeader + "data: {OTHER: x, NEW: z}\n",
+		},
+		{
+			name: "data not last",
+			text: "apiVersion: secrets.controllers.ridecell.io/v1beta2\nkind: DecryptedSecret\ndata:\n  KEY: value\nmetadata:\n  name: test\n",
+			data: map[string]string{"KEY": "value", "NEW": "z"},
+			want: "apiVersion: secrets.controllers.ridecell.io/v1beta2\nkind: DecryptedSecret\ndata:\n  KEY: value\n  NEW: z\nmetadata:\n  name: test\n",
+		},
+		{
+			name: "remove key with comments",
+			text: secretHeader + "data:\n  # before key\n  KEY: value # after value\n  OTHER: x\n",
+			data: map[string]string{"OTHER": "x"},
+			want: secretHeader + "data:\n  # before key\n  OTHER: x\n",
+		},
+		{
+			name: "quoting",
+			text: secretHeader + "data:\n  KEY: value\n",
+			data: map[string]string{"KEY": "yes", "NUM": "0123", "SPACE": " lead", "HASH": "a #b"},
+			want: secretHeader + "data:\n  KEY: \"yes\"\n  HASH: \"a #b\"\n  NUM: \"0123\"\n  SPACE: \" lead\"\n",
+		},
+		{
+			name: "null data",
+			text: secretHeader + "data: null\n",
+			data: map[string]string{"KEY": "value"},
+			want: secretHeader + "data:\n  KEY: value\n",
+		},
+		{
+			name: "null data with comment",
+			text: secretHeader + "data: null # none yet\n",
+			data: map[string]string{"KEY": "value"},
+			want: secretHeader + "data: # none yet\n  KEY: value\n",
+		},
+		{
+			name: "null data crlf",
+			text: strings.ReplaceAll(secretHeader, "\n", "\r\n") + "data: null\r\n",
+			data: map[string]string{"KEY": "value"},
+			want: strings.ReplaceAll(secretHeader, "\n", "\r\n") + "data:\r\n  KEY: value\r\n",
+		},
+		{
+			name: "crlf add keys",
+			text: strings.ReplaceAll(secretHeader+"data:\n  CERT: |\n    old\n  KEY: value # comment\n", "\n", "\r\n"),
+			data: map[string]string{"CERT": "line one\nline two", "KEY": "value", "NEW": "a\nb"},
+			want: strings.ReplaceAll(secretHeader+"data:\n  CERT: |-\n    line one\n    line two\n  KEY: value # comment\n  NEW: |-\n    a\n    b\n", "\n", "\r\n"),
+		},
+		{
+			name: "crlf no data",
+			text: strings.ReplaceAll(secretHeader, "\n", "\r\n"),
+			data: map[string]string{"KEY": "value"},
+			want: strings.ReplaceAll(secretHeader+"data:\n  KEY: value\n", "\n", "\r\n"),
+		},
+		{
+			name: "no data",
+			text: secretHeader,
+			data: map[string]string{"KEY": "value"},
+			want: secretHeader + "data:\n  KEY: value\n",
+		},
+		{
+			name: "separators kept",
+			text: "--- # secret\r\n" + secretHeader + "data:\n  KEY: value\n---\n# empty\n---\n" + configMap,
+			data: map[string]string{"KEY": "new"},
+			want: "--- # secret\r\n" + secretHeader + "data:\n  KEY: new\n---\n# empty\n---\n" + configMap,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifest := parseManifest(t, test.text)
+			obj, err := manifest.FindSecret("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj.Data = test.data
+			out := serializeManifest(t, manifest)
+			if test.want != "" && out != test.want {
+				t.Errorf("want:\n%q\ngot:\n%q", test.want, out)
+			}
+
+			// Whatever the layout, the output must read back as the new data.
+			reparsed, err := parseManifest(t, out).FindSecret("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(reparsed.Data, obj.Data) {
+				t.Errorf("output reads back as %v, want %v\n%s", reparsed.Data, obj.Data, out)
+			}
+		})
+	}
+}
@@ -17,21 +17,16 @@ limitations under the License.
 package edit
 
 import (
-	"bytes"
-	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/gob"
-	"fmt"
 	"io"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/pkg/errors"
 	"github.com/pterm/pterm"
-	"golang.org/x/crypto/nacl/secretbox"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/kubernetes/scheme"
 
@@ -44,42 +39,12 @@ const (
 	nonceLength = 24
 )
 
-var dataRegexp *regexp.Regexp
-var keyRegexp *regexp.Regexp
-var nonStringRegexp *regexp.Regexp
-
 type Payload struct {
 	Key     []byte
 	Nonce   *[nonceLength]byte
 	Message []byte
 }
 
-func init() {
-	dataRegexp = regexp.MustCompile(`(?ms)kind: (EncryptedSecret|DecryptedSecret).*?(^data:.*?)\z`)
-	keyRegexp = regexp.MustCompile("" +
-		// Turn on multiline mode for the whole pattern, ^ and $ will match on lines rather than start and end of whole string.
-		`(?m)` +
-		// Look for the key, some whitespace, then some non-space-or-:, then :
-		`^[ \t]+([^:\n\r]+):` +
-		// Whitespace between the key's : and the value
-		`[ \t]+` +
-		// Start an alternation for block scalars and normal values.
-		`(?:` +
-		// Match block scalars first because they would otherwise match the normal value pattern.
-		// Looks for the | or >, optional flags, then lines with 4 spaces of indentation. A better version of this
-		// would look more like ([|>]\n([ \t]+).+?\n(?:\3.+?\n)*) and would use a backreference instead of hardwiring
-		// things but Go, or rather RE2, refuses to support backrefs because they can be slow. Blaaaaaaah.
-		`([|>].*?(?:\n    .+?$)+)` +
-		// Alternation between block scalar and normal values.
-		`|` +
-		// Look for a normal value, something on a single line with optional trailing whitespace.
-		`(.+?)[ \t]*$` +
-		// Close the block vs. normal alternation.
-		`)`,
-	)
-	nonStringRegexp = regexp.MustCompile(`^(\d+(\.\d+)?|true|false|null|\[.*\]|)$`)
-}
-
 func NewObject(raw []byte) (*Object, error) {
 
 	// Here, we need to be able to edit the objects which are not registered in ridectl
@@ -117,81 +82,24 @@ func NewObject(raw []byte) (*Object, error) {
 	}
 
 	if o.Kind != "" {
-		// Run the regex parse. If you are reading this code, I am sorry and yes I
-		// feel bad about it. This is used when re-encoding to allow output that
+		// Parse the node tree. This is used when re-encoding to allow output that
 		// preserves comments, whitespace, key ordering, etc.
-		match := dataRegexp.FindSubmatchIndex(raw)
-		if match == nil {
-			// This shouldn't happen.
-			panic("EncryptedSecret or DecryptedSecret didn't match dataRegexp")
-		}
-		// match[0] and [1] are for the whole regexp, we don't need that.
-		o.KindLoc.Start = match[2]
-		o.KindLoc.End = match[3]
-		o.DataLoc.Start = match[4]
-		o.DataLoc.End = match[5]
-		if len(o.Data) > 0 {
-			locs, err := newKeysLocations(raw[o.DataLoc.Start:o.DataLoc.End], o.DataLoc.Start)
-			if err != nil {
-				// Also shouldn't happen.
-				panic(err.Error())
-			}
-			o.KeyLocs = locs
-		}
-
-		// A safety check for now.
-		if len(o.Data) != len(o.KeyLocs) {
-			panic("key count mismatch")
+		err = o.parseLocations()
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s", o.Kind)
 		}
 	}
 	return o, nil
 }
 
-func newKeysLocations(raw []byte, offset int) ([]KeysLocation, error) {
-	matches := keyRegexp.FindAllSubmatchIndex(raw, -1)
-	if matches == nil {
-		return nil, errors.New("unable to parse keys")
-	}
-	locs := []KeysLocation{}
-	for _, match := range matches {
-		keyStart := match[2]
-		keyEnd := match[3]
-		blockValueStart := match[4]
-		blockValueEnd := match[5]
-		normalValueStart := match[6]
-		normalValueEnd := match[7]
-		var valueLoc TextLocation
-		if normalValueStart == -1 {
-			if raw[blockValueStart] != '|' {
-				return nil, errors.New("only | block scalars are supported")
-			}
-			valueLoc.Start = blockValueStart + offset
-			valueLoc.End = blockValueEnd + offset
-		} else {
-			valueLoc.Start = normalValueStart + offset
-			valueLoc.End = normalValueEnd + offset
-		}
-		key := string(raw[keyStart:keyEnd])
-		if key[0] == '#' {
-			// Go doesn't do negative lookaheads to easier to filter comments out here.
-			continue
-		}
-		locs = append(locs, KeysLocation{TextLocation: valueLoc, Key: key})
-	}
-	return locs, nil
-}
-
-func (o *Object) Decrypt(kmsService *kms.Client, recrypt bool) error {
-	if o.Kind == "" {
+func (o *Object) Decrypt(keyProvider KeyProvider, recrypt bool) error {
+	// Nothing to decrypt for a DecryptedSecret, e.g. from the new instance template.
+	if o.Kind == "" || o.OrigEnc == nil {
 		return nil
 	}
 
-	// Key map for holding plainDataKey to avoid repetative KMS decrypt calls for single cipherDataKey
-	keyMap := map[string]*[32]byte{}
-
 	dec := &hacksecretsv1beta2.DecryptedSecret{ObjectMeta: o.OrigEnc.ObjectMeta, Data: map[string]string{}}
 
-	var keyId string
 	// If EncryptedSecret is encrypted using mulitple keyIds,
 	// determine most used keyId, and use it to encrypt all value
 	keyUsageCount := map[string]int{}
@@ -201,40 +109,23 @@ func (o *Object) Decrypt(kmsService *kms.Client, recrypt bool) error {
 	keyIdCipherDataKeyMap := map[string][]byte{}
 
 	for key, value := range o.OrigEnc.Data {
-		useDataKey := false
-
-		if strings.HasPrefix(value, "crypto") {
-			useDataKey = true
-			array := strings.Split(value, " ")
-			value = array[len(array)-1]
-		}
-
-		decodedValue := make([]byte, base64.StdEncoding.DecodedLen(len(value)))
-		l, err := base64.StdEncoding.Decode(decodedValue, []byte(value))
-		if err != nil {
-			return errors.Wrapf(err, "error base64 decoding value for %s", key)
-		}
+		// If in one of the envelope formats, decrypt using data key
+		if IsPayload(value) {
+			p, algorithm, err := DecodePayload(value)
+			if err != nil {
+				return errors.Wrapf(err, "error decoding value for %s", key)
+			}
 
-		// If True, decrypt using data key
-		if useDataKey {
-			var p Payload
-			_ = gob.NewDecoder(bytes.NewReader(decodedValue)).Decode(&p)
-
-			plainDataKey, ok := keyMap[string(p.Key)]
-			if !ok {
-				// Decrypt cipherdatakey
-				plainDataKey, keyId, err = DecryptCipherDataKey(kmsService, p.Key)
-				if err != nil {
-					return errors.Wrapf(err, "error decrypting value for cipherDatakey")
-				}
-				keyMap[string(p.Key)] = plainDataKey
+			// Decrypt cipherdatakey, only the first use of each calls the key provider
+			plainDataKey, keyId, err := DecryptCipherDataKey(keyProvider, p.Key)
+			if err != nil {
+				return errors.Wrapf(err, "error decrypting value for cipherDatakey")
 			}
 
 			// Decrypt message
-			var plaintext []byte
-			plaintext, ok = secretbox.Open(plaintext, p.Message, p.Nonce, plainDataKey)
-			if !ok {
-				return errors.Errorf("error decrypting value with data key for %s", key)
+			plaintext, err := OpenPayload(p, algorithm, plainDataKey, AdditionalData(o.OrigEnc.Namespace, o.OrigEnc.Name, key))
+			if err != nil {
+				return errors.Wrapf(err, "error decrypting value with data key for %s", key)
 			}
 			dec.Data[key] = string(plaintext)
 			keyUsageCount[keyId] = keyUsageCount[keyId] + 1
@@ -244,19 +135,20 @@ func (o *Object) Decrypt(kmsService *kms.Client, recrypt bool) error {
 			continue
 		}
 
-		// Decrypt using KMS service
-		decryptedValue, err := kmsService.Decrypt(context.TODO(), &kms.DecryptInput{
-			CiphertextBlob: decodedValue[:l],
-			EncryptionContext: map[string]string{
-				"RidecellOperator": "true",
-			},
-		})
+		decodedValue := make([]byte, base64.StdEncoding.DecodedLen(len(value)))
+		l, err := base64.StdEncoding.Decode(decodedValue, []byte(value))
+		if err != nil {
+			return errors.Wrapf(err, "error base64 decoding value for %s", key)
+		}
+
+		// Decrypt using the key provider directly
+		decryptedValue, valueKeyId, err := keyProvider.Decrypt(decodedValue[:l])
 		if err != nil {
 			return errors.Wrapf(err, "error decrypting value for %s", key)
 		}
-		keyUsageCount[*decryptedValue.KeyId] = keyUsageCount[*decryptedValue.KeyId] + 1
+		keyUsageCount[valueKeyId] = keyUsageCount[valueKeyId] + 1
 
-		decryptedString := string(decryptedValue.Plaintext)
+		decryptedString := string(decryptedValue)
 		if decryptedString == secretsv1beta2.EncryptedSecretEmptyKey {
 			decryptedString = ""
 		}
@@ -266,14 +158,17 @@ func (o *Object) Decrypt(kmsService *kms.Client, recrypt bool) error {
 
 	// use key with maximum usage count
 	maxUsageCount := 0
+	o.KeyIds = []string{}
 	for k, c := range keyUsageCount {
 		if maxUsageCount < c {
 			o.KeyId = k
 			maxUsageCount = c
 		}
+		o.KeyIds = append(o.KeyIds, k)
 	}
+	sort.Strings(o.KeyIds)
 	if len(keyUsageCount) > 1 && !recrypt {
-		pterm.Warning.Printf("Multiple keyIds used to encrypt secret values, using most used keyId to encrypt all values: %s\nTo override keyId, you can use -k flag. For more details, use: ridectl edit -h\n", getAliasByKey(kmsService, o.KeyId))
+		pterm.Warning.Printf("Multiple keyIds used to encrypt secret values, using most used keyId to encrypt all values: %s\nTo override keyId, you can use -k flag. For more details, use: ridectl edit -h\n", getAliasByKey(keyProvider, o.KeyId))
 	}
 
 	o.PlainDataKey = keyIdDataKeyMap[o.KeyId]
@@ -284,7 +179,7 @@ func (o *Object) Decrypt(kmsService *kms.Client, recrypt bool) error {
 	return nil
 }
 
-func (o *Object) Encrypt(kmsService *kms.Client, defaultKeyId string, forceKeyId bool, reEncrypt bool) error {
+func (o *Object) Encrypt(keyProvider KeyProvider, defaultKeyId string, forceKeyId bool, reEncrypt bool) error {
 	if o.Kind == "" {
 		return nil
 	}
@@ -305,11 +200,13 @@ func (o *Object) Encrypt(kmsService *kms.Client, defaultKeyId string, forceKeyId
 	}
 
 	enc := &secretsv1beta2.EncryptedSecret{ObjectMeta: o.AfterDec.ObjectMeta, Data: map[string]string{}}
+	envelopeKeyId := ""
 
 	for key, value := range o.AfterDec.Data {
 
-		// Check if this key has changed.
-		if o.OrigDec != nil && o.OrigEnc != nil && !reEncrypt {
+		// Check if this key has changed. v2 values are bound to the namespace
+		// and name, so a renamed or moved secret is encrypted again.
+		if o.OrigDec != nil && o.OrigEnc != nil && !reEncrypt && o.AfterDec.Namespace == o.OrigEnc.Namespace && o.AfterDec.Name == o.OrigEnc.Name {
 			origDecValue, ok := o.OrigDec.Data[key]
 			if ok && value == origDecValue {
 				// Key was not changed, reuse the old encrypted value.
@@ -322,7 +219,7 @@ func (o *Object) Encrypt(kmsService *kms.Client, defaultKeyId string, forceKeyId
 		// check if o.PlainDataKey is populated, if not create data key
 		if o.PlainDataKey == nil {
 			var err error
-			o.PlainDataKey, o.CipherDataKey, err = GenerateDataKey(kmsService, keyId)
+			o.PlainDataKey, o.CipherDataKey, err = GenerateDataKey(keyProvider, keyId)
 			if err != nil {
 				return errors.Wrapf(err, "error generating data key")
 			}
@@ -339,18 +236,27 @@ func (o *Object) Encrypt(kmsService *kms.Client, defaultKeyId string, forceKeyId
 			return errors.Wrapf(err, "error generating nonce for %s", key)
 		}
 
-		// Encrypt message
-		p.Message = secretbox.Seal(p.Message, []byte(value), p.Nonce, o.PlainDataKey)
-		buf := &bytes.Buffer{}
-		if err := gob.NewEncoder(buf).Encode(p); err != nil {
+		// Encrypt message, v2 values are bound to where they are stored.
+		var additionalData []byte
+		if WriteEnvelope == EnvelopeV2 {
+			additionalData = AdditionalData(enc.Namespace, enc.Name, key)
+		}
+		algorithm, err := SealPayload(p, []byte(value), o.PlainDataKey, additionalData)
+		if err != nil {
 			return errors.Wrapf(err, "error encrypting value using data key for %s", key)
 		}
-
-		enc.Data[key] = fmt.Sprintf("crypto %s", string(base64.StdEncoding.EncodeToString(buf.Bytes())))
+		if envelopeKeyId == "" {
+			envelopeKeyId = recordedKeyId(keyProvider, keyId, defaultKeyId)
+		}
+		encValue, err := EncodePayload(p, envelopeKeyId, algorithm, WriteEnvelope)
+		if err != nil {
+			return errors.Wrapf(err, "error encrypting value using data key for %s", key)
+		}
+		enc.Data[key] = encValue
 	}
 
 	if keyId != "" && len(o.AfterDec.Data) > 0 {
-		pterm.Info.Printf("Encrypted using %s\n", getAliasByKey(kmsService, keyId))
+		pterm.Info.Printf("Encrypted using %s\n", getAliasByKey(keyProvider, keyId))
 	}
 	o.AfterEnc = enc
 	o.Kind = "EncryptedSecret"
@@ -358,134 +264,133 @@ func (o *Object) Encrypt(kmsService *kms.Client, defaultKeyId string, forceKeyId
 	return nil
 }
 
+// UnboundKeys returns the sorted keys of an EncryptedSecret read from a
+// manifest whose values are not bound to it, see IsBound.
+func (o *Object) UnboundKeys() []string {
+	keys := []string{}
+	if o.OrigEnc == nil {
+		return keys
+	}
+	for key, value := range o.OrigEnc.Data {
+		if !IsBound(value) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (o *Object) Serialize(out io.Writer) error {
 	// Check if this is one of the two types we care about.
-	if o.Data == nil {
+	if o.Kind == "" {
 		// Nope, we're out.
 		_, err := out.Write(o.Raw)
 		return err
 	}
 
-	// Start writing!
-	_, err := out.Write(o.Raw[0:o.KindLoc.Start])
-	if err != nil {
-		return err
+	// Work out the edits to make to the raw text, in the order they appear.
+	// Insertions sort ahead of any removal starting at the same place.
+	edits := []textEdit{}
+	if edit, ok := o.KindLoc.replace(o.Kind); ok {
+		edits = append(edits, edit)
 	}
-	_, err = out.Write([]byte(o.Kind))
+	dataEdits, err := o.dataEdits()
 	if err != nil {
 		return err
 	}
-	// Track where we are up to.
-	carry := o.KindLoc.End
-	for _, keyLoc := range o.KeyLocs {
-		newValue, ok := o.Data[keyLoc.Key]
-		if !ok {
-			panic("key from location not found in data")
-		}
-
-		// Check for a multiline value.
-		if strings.ContainsRune(newValue, '\n') {
-			var buf strings.Builder
-			buf.WriteString("|")
-			lines := strings.Split(newValue, "\n")
-			// Trim the trailing newline, basically.
-			if lines[len(lines)-1] == "" {
-				lines = lines[:len(lines)-1]
-			}
-			for _, line := range lines {
-				// Hardwire to 4 spaces of indentation, which assumes 2 spaces on the keys.
-				// I'll probably regret this when someone uses 8 space indents.
-				buf.WriteString("\n    ")
-				buf.WriteString(line)
-			}
-			newValue = buf.String()
+	edits = append(edits, dataEdits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].Start != edits[j].Start {
+			return edits[i].Start < edits[j].Start
 		}
+		return edits[i].End < edits[j].End
+	})
 
-		// Check for things that YAML thinks aren't strings that might show up in the value.
-		if nonStringRegexp.MatchString(newValue) {
-			newValue = fmt.Sprintf(`"%s"`, newValue)
+	// Start writing!
+	carry := 0
+	for _, edit := range edits {
+		if edit.Start < carry {
+			return errors.New("overlapping edits")
 		}
-		_, err = out.Write(o.Raw[carry:keyLoc.Start])
+		_, err = out.Write(o.Raw[carry:edit.Start])
 		if err != nil {
 			return err
 		}
-		_, err = out.Write([]byte(newValue))
+		_, err = out.Write([]byte(edit.Text))
 		if err != nil {
 			return err
 		}
-		carry = keyLoc.End
+		carry = edit.End
 	}
 	_, err = out.Write(o.Raw[carry:])
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return err
 }
 
-func GenerateDataKey(kmsService *kms.Client, keyId string) (*[32]byte, []byte, error) {
+func GenerateDataKey(keyProvider KeyProvider, keyId string) (*[32]byte, []byte, error) {
 	// Generate data key
-	rsp, err := kmsService.GenerateDataKey(context.TODO(), &kms.GenerateDataKeyInput{
-		KeyId:         aws.String(keyId),
-		NumberOfBytes: aws.Int32(32),
-		EncryptionContext: map[string]string{
-			"RidecellOperator": "true",
-		},
-	})
+	plaintext, ciphertext, err := keyProvider.GenerateDataKey(keyId)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	key := &[32]byte{}
-	copy(key[:], rsp.Plaintext)
+	copy(key[:], plaintext)
 
-	return key, rsp.CiphertextBlob, nil
+	return key, ciphertext, nil
 }
 
-func DecryptCipherDataKey(kmsService *kms.Client, cipherDataKey []byte) (*[32]byte, string, error) {
-	decryptRsp, err := kmsService.Decrypt(context.TODO(), &kms.DecryptInput{
-		CiphertextBlob: cipherDataKey,
-		EncryptionContext: map[string]string{
-			"RidecellOperator": "true",
-		},
+// DecryptCipherDataKey decrypts a data key with the key provider. Data keys
+// are cached for the whole run, and shared by every object and file using them.
+func DecryptCipherDataKey(keyProvider KeyProvider, cipherDataKey []byte) (*[32]byte, string, error) {
+	return dataKeys.get(cipherDataKey, func() (*[32]byte, string, error) {
+		plaintext, keyId, err := keyProvider.Decrypt(cipherDataKey)
+		if err != nil {
+			return nil, "", err
+		}
+		plainDataKey := &[32]byte{}
+		copy(plainDataKey[:], plaintext)
+
+		pterm.Info.Printf("Decrypted using %s\n", getAliasByKey(keyProvider, keyId))
+		return plainDataKey, keyId, nil
 	})
+}
 
-	if err != nil {
-		return nil, "", err
+// recordedKeyId is the key recorded in v2 values. Decrypt returns key ARNs,
+// which are recorded by their alias instead so lint can check them offline,
+// preferring defaultKeyId when it is one of them.
+func recordedKeyId(keyProvider KeyProvider, keyId string, defaultKeyId string) string {
+	if WriteEnvelope != EnvelopeV2 || strings.HasPrefix(keyId, "alias/") {
+		return keyId
 	}
-	plainDataKey := &[32]byte{}
-	copy(plainDataKey[:], decryptRsp.Plaintext)
-
-	pterm.Info.Printf("Decrypted using %s\n", getAliasByKey(kmsService, *decryptRsp.KeyId))
-	return plainDataKey, *decryptRsp.KeyId, nil
+	aliases, err := keyProvider.ListAliases(keyId)
+	if err != nil || len(aliases) == 0 {
+		return keyId
+	}
+	if slices.Contains(aliases, defaultKeyId) {
+		return defaultKeyId
+	}
+	sort.Strings(aliases)
+	return aliases[0]
 }
 
-func getAliasByKey(kmsService *kms.Client, keyId string) string {
+func getAliasByKey(keyProvider KeyProvider, keyId string) string {
 
 	// check if the key is an alias
 	if strings.HasPrefix(keyId, "alias") {
 		return keyId
 	}
 	// get aliasname from key id
-	var aliases []string
-	aliasRsp, err := kmsService.ListAliases(context.TODO(), &kms.ListAliasesInput{
-		KeyId: aws.String(keyId),
-	})
+	aliases, err := keyProvider.ListAliases(keyId)
 	if err != nil {
 		pterm.Error.Println("Error getting alias for key")
 		return keyId
 	}
 
-	aliasList := aliasRsp.Aliases
-	if len(aliasList) == 0 {
+	if len(aliases) == 0 {
 		pterm.Warning.Println("Error getting alias for key")
 		return keyId
 	}
 
-	for alias := range aliasList {
-		aliases = append(aliases, *aliasList[alias].AliasName)
-	}
-
 	return strings.Join(aliases, ",")
 
 }
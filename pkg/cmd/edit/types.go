@@ -17,6 +17,7 @@ limitations under the License.
 package edit
 
 import (
+	yaml "go.yaml.in/yaml/v3"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	secretsv1beta2 "github.com/Ridecell/ridecell-controllers/apis/secrets/v1beta2"
@@ -32,6 +33,13 @@ type Object struct {
 	// The original object as decoded by UniversalDeserializer.
 	Object runtime.Object
 	Meta   metav1.Object
+	// The line the object starts on in the manifest file.
+	Line int
+	// The text around the object in the manifest file: separators and
+	// comment-only documents before it, and the end of the file after the
+	// last object.
+	Before []byte
+	After  []byte
 
 	// Tracking for the various stages of encryption and decryption.
 	OrigEnc  *secretsv1beta2.EncryptedSecret
@@ -44,13 +52,16 @@ type Object struct {
 	// The KMS KeyId used for this object, if known. If nil, it might be a new
 	// object.
 	KeyId string
+	// All the KMS KeyIds used by the values of this object, after decryption.
+	KeyIds []string
 	// The Plaintext Data key and Cipher Key generated using KMS Key ID
 	PlainDataKey  *[32]byte
 	CipherDataKey []byte
 
-	// Byte coordinates for areas of the raw text we need to edit when re-serializing.
-	KindLoc TextLocation
-	DataLoc TextLocation
+	// The raw text parsed as a YAML node tree, used to find the areas of the
+	// raw text we need to edit when re-serializing.
+	Doc     *yaml.Node
+	KindLoc KeysLocation
 	KeyLocs []KeysLocation
 }
 
@@ -61,5 +72,18 @@ type TextLocation struct {
 
 type KeysLocation struct {
 	TextLocation
-	Key string
+	Key      string
+	KeyStart int
+	// The value as originally parsed.
+	Value *yaml.Node
+	// Whether the value is inside a flow mapping, and so can only be written on one line.
+	Flow bool
+	// The indentation to use for the lines of a block scalar.
+	Indent int
+	// A comment on the same line as the value. For block scalars it is part of
+	// the header and inside the text location, otherwise it runs up to CommentEnd.
+	Comment    string
+	CommentEnd int
+	// The line ending of the document, for the lines of a block scalar.
+	Newline string
 }
@@ -17,18 +17,20 @@ limitations under the License.
 package cmd
 
 import (
-	"bytes"
 	"crypto/rand"
-	"encoding/base64"
-	"encoding/gob"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/Ridecell/ridectl/pkg/cmd/edit"
-	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/Ridecell/ridectl/pkg/kubernetes"
 	"github.com/pkg/errors"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
-	"golang.org/x/crypto/nacl/secretbox"
 )
 
 func init() {
@@ -36,25 +38,67 @@ func init() {
 }
 
 var recrypt bool
+var stdoutFlag bool
+var outputFileFlag string
+var recursiveFlag bool
+var includeFlag []string
+var excludeFlag []string
+var encryptCheckFlag bool
+var allowKeyMismatchFlag bool
+
+// Key used when neither -k nor .keys.yml gives one.
+const defaultEncryptKeyId = "alias/microservices_dev"
+
+// Environments which must not use dev keys.
+var protectedEnvs = []string{"prod", "uat"}
+
+// File in the root of a directory encrypted with -R listing the sensitive paths.
+const encryptListFile = ".ridectl-encrypt"
 
 func init() {
 	encryptCmd.Flags().BoolVarP(&recrypt, "recrypt", "r", false, "(optional) re-encrypts the file")
 	encryptCmd.Flags().StringVarP(&keyIdFlag, "key", "k", "", "(optional) KMS key ID / key alias to use for encrypting")
+	encryptCmd.Flags().StringVar(&keyProviderFlag, "key-provider", "", keyProviderUsage)
+	encryptCmd.Flags().StringVar(&envelopeFlag, "envelope", edit.EnvelopeV1, envelopeUsage)
+	encryptCmd.Flags().BoolVar(&stdoutFlag, "stdout", false, "(optional) Write to stdout instead of <file-name>.encrypted")
+	// Shadows the global -o output format, which encrypt doesn't use.
+	encryptCmd.Flags().StringVar(&outputFileFlag, "output", "", "(optional) Path to write to instead of <file-name>.encrypted")
+	encryptCmd.Flags().BoolVarP(&recursiveFlag, "recursive", "R", false, "(optional) Encrypt the files in directories listed in their "+encryptListFile+" or matching --include")
+	encryptCmd.Flags().StringSliceVar(&includeFlag, "include", nil, "(optional) Glob of files to encrypt with -R, can be repeated")
+	encryptCmd.Flags().StringSliceVar(&excludeFlag, "exclude", nil, "(optional) Glob of files to skip with -R, can be repeated")
+	encryptCmd.Flags().BoolVar(&encryptCheckFlag, "check", false, "(optional) Only check that each file matches its .encrypted copy, failing if not")
+	encryptCmd.Flags().BoolVar(&allowKeyMismatchFlag, "allow-key-mismatch", false, "(optional) Encrypt prod/uat files with a dev key, or a key .keys.yml does not allow, with a warning")
 }
 
 /*
 An explanation of the encrypt process:
 
-1. Generated KMS data key using given key id
-2. The existing file is loaded.
+1. Work out the key for each file, from -k, .keys.yml or the dev key. Files in
+   prod/uat, by the env tag in .keys.yml or the <region>-<env> and
+   <tenant>-<env> names in their path, may not use a dev key, and the key must
+   pass the .keys.yml policies, unless --allow-key-mismatch is set
+   Then generate a KMS data key for each key used
+2. The existing file is loaded, or stdin for -
 3. First check if its encrypted copy exists, and has no change in data
 4. If file is changed, then encrypt the file data using data key
-5. Write encrypted data to file
+5. Write encrypted data to file, or stdout for - and --stdout
+
+With -R, the arguments are directories. The files to encrypt are those matching
+a glob in the .ridectl-encrypt file in the directory, one per line, or an
+--include glob, and not matching an --exclude glob. Globs without a / match the
+file name anywhere, others match the path from the directory, and globs ending
+in / match everything under that path.
+
+With --check nothing is written. Instead every file is compared with its
+.encrypted copy, failing if any copy is missing, has different content, or is
+older than the file. Encrypting a file whose content did not change still
+updates the modification time of its copy, and decrypt sets the time of the
+files it writes to that of their copies, so they pass.
 
 */
 
 var encryptCmd = &cobra.Command{
-	Use:   "encrypt [-k <kms-key-alias>] [-r] <file-names>",
+	Use:   "encrypt [-k <kms-key-alias>] [-r] [--stdout | --output <path>] [--check] <file-names | -> | -R <dirs>",
 	Short: "Encrypt files",
 	Long:  `encrypt files that has secret values`,
 	Args: func(_ *cobra.Command, args []string) error {
@@ -65,53 +109,98 @@ var encryptCmd = &cobra.Command{
 		return nil
 	},
 	RunE: func(_ *cobra.Command, fileNames []string) error {
-		// Check if key id is provided
-		keyId := keyIdFlag
-		if len(keyId) == 0 {
-			keyId = "alias/microservices_dev"
-			pterm.Info.Printf("---------------\nWARNING: Using %s KMS key by default, Please specify other key for Prod/UAT environment using -k option.\n         For example: ridectl encrypt -k alias/<key-alias> [file-names]\n---------------\n", keyId)
+		err := edit.SetEnvelope(envelopeFlag)
+		if err != nil {
+			return err
 		}
-		pterm.Info.Println("Encrypting using key: " + keyId)
-
-		cfg, err := getAWSConfig("kms-grants", "us-west-1")
+		toStdout, err := streamOutput(fileNames)
 		if err != nil {
-			return errors.Wrapf(err, "error creating AWS session")
+			return err
+		}
+		if recursiveFlag {
+			fileNames, err = findFilesToEncrypt(fileNames, false)
+			if err != nil {
+				return err
+			}
+			if len(fileNames) == 0 {
+				pterm.Info.Println("No files to encrypt")
+				return nil
+			}
 		}
 
-		// Create an Amazon KMS service client
-		kmsService := kms.NewFromConfig(cfg)
+		if encryptCheckFlag {
+			keyProvider, err := getKeyProvider(keySettingsFile(fileNames[0]))
+			if err != nil {
+				return err
+			}
+			return checkEncryptedFiles(keyProvider, fileNames)
+		}
 
-		plainDataKey, cipherDataKey, err := edit.GenerateDataKey(kmsService, keyId)
+		keyProvider, err := getKeyProvider(keySettingsFile(fileNames[0]))
 		if err != nil {
-			return errors.Wrapf(err, "error generating data key using KMS key: %s", keyId)
+			return err
+		}
+
+		// Check every file's key before encrypting any.
+		keyIds := make([]string, len(fileNames))
+		for i, filename := range fileNames {
+			keyIds[i], err = encryptKeyId(keyProvider, keySettingsFile(filename))
+			if err != nil {
+				return err
+			}
 		}
 
+		plainDataKeys := map[string]*[32]byte{}
+		cipherDataKeys := map[string][]byte{}
 		var p *edit.Payload
-		for _, filename := range fileNames {
+		for i, filename := range fileNames {
+			keyId := keyIds[i]
 			// read file content
-			fileContent, err := os.ReadFile(filename)
+			fileContent, err := readInput(filename)
 			if err != nil {
 				return errors.Wrapf(err, "error reading file: %s", filename)
 			}
+			outFilename := filename + ".encrypted"
+			if outputFileFlag != "" {
+				outFilename = outputFileFlag
+			}
 
 			// Check if there is need to encrypt the file - the file content is changed.
-			if !recrypt {
-				encryptedFileContent, err := os.ReadFile(filename + ".encrypted")
+			if !recrypt && !toStdout {
+				encryptedFileContent, err := os.ReadFile(outFilename)
 				if err == nil {
-					decryptedFileContent, err := GetDecryptedData(kmsService, encryptedFileContent)
+					decryptedFileContent, err := GetDecryptedData(keyProvider, encryptedFileContent)
 					if err == nil {
 						// If file content is not changed, then continue with next file
 						if string(fileContent) == string(decryptedFileContent) {
-							pterm.Info.Println("No changes: " + filename + ".encrypted")
+							pterm.Info.Println("No changes: " + outFilename)
+							if filename != "-" && isNewer(filename, outFilename) {
+								now := time.Now()
+								err = os.Chtimes(outFilename, now, now)
+								if err != nil {
+									return errors.Wrapf(err, "error updating file: %s", outFilename)
+								}
+							}
 							continue
 						}
 					}
 				}
 			}
 
+			// One data key per key
+			plainDataKey, ok := plainDataKeys[keyId]
+			if !ok {
+				pterm.Info.Println("Encrypting using key: " + keyId)
+				plainDataKey, cipherDataKeys[keyId], err = edit.GenerateDataKey(keyProvider, keyId)
+				if err != nil {
+					return errors.Wrapf(err, "error generating data key using KMS key: %s", keyId)
+				}
+				plainDataKeys[keyId] = plainDataKey
+			}
+
 			// encrypt file content
 			p = &edit.Payload{
-				Key:   cipherDataKey,
+				Key:   cipherDataKeys[keyId],
 				Nonce: &[24]byte{},
 			}
 			// Set nonce
@@ -119,21 +208,320 @@ var encryptCmd = &cobra.Command{
 				return errors.Wrap(err, "error generating nonce.")
 			}
 			// Encrypt message
-			p.Message = secretbox.Seal(p.Message, fileContent, p.Nonce, plainDataKey)
-			buf := &bytes.Buffer{}
-			if err = gob.NewEncoder(buf).Encode(p); err != nil {
+			algorithm, err := edit.SealPayload(p, fileContent, plainDataKey, nil)
+			if err != nil {
+				return errors.Wrapf(err, "error encrypting value using data key for file %s", filename)
+			}
+			encryptedFileContent, err := edit.EncodePayload(p, keyId, algorithm, edit.WriteEnvelope)
+			if err != nil {
 				return errors.Wrapf(err, "error encrypting value using data key for file %s", filename)
 			}
-			encryptedFileContent := string(base64.StdEncoding.EncodeToString(buf.Bytes()))
+			// v1 files hold just the base64 payload, without the crypto prefix.
+			encryptedFileContent = strings.TrimPrefix(encryptedFileContent, "crypto ")
 
 			// write encrypted content in <filename>.encrypted
-			err = os.WriteFile(filename+".encrypted", []byte(encryptedFileContent), 0644)
+			err = writeOutput(outFilename, toStdout, []byte(encryptedFileContent))
 			if err != nil {
-				return errors.Wrapf(err, "error writing file: %s", filename)
+				return errors.Wrapf(err, "error writing file: %s", outFilename)
+			}
+			if !toStdout {
+				pterm.Success.Println("Encrypted : " + outFilename)
 			}
-			pterm.Success.Println("Encrypted : " + filename + ".encrypted")
 		}
 
 		return nil
 	},
 }
+
+// streamOutput checks the --stdout and --output flags against the
+// arguments, before -R expands them, returning if the output goes to stdout.
+// Reading from stdin with - writes to stdout unless --output is given.
+func streamOutput(fileNames []string) (bool, error) {
+	if stdoutFlag && outputFileFlag != "" {
+		return false, errors.New("--stdout and --output can't be used together")
+	}
+	if recursiveFlag && (stdoutFlag || outputFileFlag != "" || slices.Contains(fileNames, "-")) {
+		return false, errors.New("--stdout, --output and - can't be used with -R")
+	}
+	if (stdoutFlag || outputFileFlag != "" || slices.Contains(fileNames, "-")) && len(fileNames) > 1 {
+		return false, errors.New("--stdout, --output and - can only be used with a single file")
+	}
+	toStdout := stdoutFlag || (fileNames[0] == "-" && outputFileFlag == "")
+	if toStdout {
+		// Keep stdout for the data only.
+		pterm.SetDefaultOutput(os.Stderr)
+	}
+	return toStdout, nil
+}
+
+// keySettingsFile returns the file to look for .keys.yml next to, which is
+// the output file when reading stdin.
+func keySettingsFile(filename string) string {
+	if filename == "-" {
+		return outputFileFlag
+	}
+	return filename
+}
+
+// readInput reads a file, or stdin for -.
+func readInput(filename string) ([]byte, error) {
+	if filename == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(filename)
+}
+
+// writeOutput writes to a file, or stdout.
+func writeOutput(filename string, toStdout bool, data []byte) error {
+	if toStdout {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// findFilesToEncrypt walks directories for the files to encrypt with -R, or
+// the .encrypted copies of them to decrypt.
+func findFilesToEncrypt(dirs []string, encrypted bool) ([]string, error) {
+	fileNames := []string{}
+	for _, dir := range dirs {
+		include := append([]string{}, includeFlag...)
+		listed, err := os.ReadFile(filepath.Join(dir, encryptListFile))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "error reading %s", encryptListFile)
+		}
+		for _, line := range strings.Split(string(listed), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				include = append(include, line)
+			}
+		}
+		// Only decrypting can do without a list, it takes every .encrypted file.
+		if len(include) == 0 && !encrypted {
+			return nil, errors.Errorf("no files to encrypt in %s, list them in %s or use --include", dir, encryptListFile)
+		}
+
+		err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || strings.HasSuffix(path, ".encrypted") != encrypted {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, strings.TrimSuffix(path, ".encrypted"))
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if (len(include) == 0 || matchesGlob(include, rel)) && !matchesGlob(excludeFlag, rel) {
+				fileNames = append(fileNames, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "error walking %s", dir)
+		}
+	}
+	return fileNames, nil
+}
+
+// matchesGlob checks a slash separated relative path against globs.
+func matchesGlob(globs []string, rel string) bool {
+	for _, glob := range globs {
+		if edit.MatchGlob(glob, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEncryptedFiles compares files with their .encrypted copies, reporting
+// and failing on any which are out of sync.
+func checkEncryptedFiles(keyProvider edit.KeyProvider, fileNames []string) error {
+	outOfSync := 0
+	for _, filename := range fileNames {
+		encFilename := filename + ".encrypted"
+		fileInfo, err := os.Stat(filename)
+		if err != nil {
+			return errors.Wrapf(err, "error reading file: %s", filename)
+		}
+		encInfo, err := os.Stat(encFilename)
+		if err != nil {
+			pterm.Warning.Printf("%s is not encrypted, run ridectl encrypt\n", filename)
+			outOfSync++
+			continue
+		}
+
+		fileContent, err := os.ReadFile(filename)
+		if err != nil {
+			return errors.Wrapf(err, "error reading file: %s", filename)
+		}
+		encryptedFileContent, err := os.ReadFile(encFilename)
+		if err != nil {
+			return errors.Wrapf(err, "error reading file: %s", encFilename)
+		}
+		decryptedFileContent, err := GetDecryptedData(keyProvider, encryptedFileContent)
+		if err != nil {
+			return errors.Wrapf(err, "filename: %s", encFilename)
+		}
+		newer := fileInfo.ModTime().After(encInfo.ModTime())
+		if string(fileContent) == string(decryptedFileContent) && !newer {
+			continue
+		}
+
+		// Work out which side most likely changed.
+		if newer {
+			pterm.Warning.Printf("%s is newer than %s, run ridectl encrypt\n", filename, encFilename)
+		} else {
+			pterm.Warning.Printf("%s differs from %s, run ridectl decrypt\n", filename, encFilename)
+		}
+		outOfSync++
+	}
+	if outOfSync > 0 {
+		return errors.Errorf("%d of %d files are out of sync with their encrypted copies", outOfSync, len(fileNames))
+	}
+	pterm.Success.Printf("All %d files match their encrypted copies\n", len(fileNames))
+	return nil
+}
+
+// isNewer checks if a file was modified after another, false if either can't
+// be read.
+func isNewer(filename string, than string) bool {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return false
+	}
+	thanInfo, err := os.Stat(than)
+	if err != nil {
+		return false
+	}
+	return info.ModTime().After(thanInfo.ModTime())
+}
+
+// encryptKeyId works out the key to encrypt a file with, checking it suits
+// the file. For stdin without --output there is no file to check, so
+// the key must be given.
+func encryptKeyId(keyProvider edit.KeyProvider, filename string) (string, error) {
+	keyId := keyIdFlag
+	if keyId == "" && filename == "" {
+		return "", errors.New("-k is required to encrypt stdin without --output, as there is no file to pick the key for")
+	}
+	if keyId == "" && filename != "" {
+		var err error
+		keyId, err = edit.FindKeyId(filename)
+		if err != nil {
+			return "", errors.Wrap(err, "error finding key ID")
+		}
+	}
+	if keyId == "" {
+		keyId = defaultEncryptKeyId
+		pterm.Info.Printf("---------------\nWARNING: Using %s KMS key by default for %s, Please specify other key for Prod/UAT environment using -k option or .keys.yml.\n         For example: ridectl encrypt -k alias/<key-alias> [file-names]\n---------------\n", keyId, filename)
+	}
+	if filename == "" {
+		return keyId, nil
+	}
+
+	err := edit.CheckKeyPolicy(keyProvider, filename, keyId)
+	if err == nil {
+		env, err2 := inferEnv(filename)
+		if err2 != nil {
+			return "", err2
+		}
+		if slices.Contains(protectedEnvs, env) && isDevKey(keyProvider, keyId) {
+			err = errors.Errorf("%s is a %s file, but %s is a dev key", filename, env, keyId)
+		}
+	}
+	if err != nil {
+		if !allowKeyMismatchFlag {
+			return "", errors.Wrap(err, "use -k to pick the right key, or --allow-key-mismatch")
+		}
+		pterm.Warning.Println(err.Error())
+	}
+	return keyId, nil
+}
+
+// inferEnv returns the environment of a file, from its env tag in .keys.yml,
+// or the closest <tenant>-<env> or <region>-<env> name in its path. Only
+// names up to the directory of .keys.yml, or else below the top of the git
+// repository, count, so unrelated directories such as /home/x-prod don't
+// decide it. Outside a repository only the file's own directory counts.
+func inferEnv(filename string) (string, error) {
+	settings, err := edit.LoadKeySettings(filename)
+	if err != nil {
+		return "", err
+	}
+	env := settings.Env(filename)
+	if env != "" {
+		return env, nil
+	}
+
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		return "", errors.Wrapf(err, "error finding path of %s", filename)
+	}
+	top := ""
+	if settings.Found {
+		top, err = filepath.Abs(filepath.Dir(settings.Path))
+		if err != nil {
+			return "", errors.Wrapf(err, "error finding path of %s", settings.Path)
+		}
+	} else if !inGitRepo(absPath) {
+		top = filepath.Dir(absPath)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(absPath), filepath.Ext(absPath))
+	for path := absPath; ; {
+		subject, err := kubernetes.ParseSubject(name)
+		if err == nil && slices.Contains([]string{"prod", "uat", "qa", "dev", "sandbox"}, subject.Env) {
+			return subject.Env, nil
+		}
+		parent := filepath.Dir(path)
+		if path == top || parent == path || isGitRoot(parent) {
+			return "", nil
+		}
+		path = parent
+		name = filepath.Base(path)
+	}
+}
+
+// isGitRoot checks if a directory is the top of a git repository.
+func isGitRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// inGitRepo checks if a path is inside a git repository.
+func inGitRepo(path string) bool {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if isGitRoot(dir) {
+			return true
+		}
+		if dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// isDevKey checks if a key, or any of its aliases, is a dev key.
+func isDevKey(keyProvider edit.KeyProvider, keyId string) bool {
+	names := []string{keyId}
+	if !strings.HasPrefix(keyId, "alias/") {
+		aliases, err := keyProvider.ListAliases(keyId)
+		if err == nil {
+			names = append(names, aliases...)
+		}
+	}
+	for _, name := range names {
+		name = strings.TrimPrefix(name, "alias/")
+		if name == strings.TrimPrefix(defaultEncryptKeyId, "alias/") || strings.HasSuffix(name, "_dev") || strings.HasSuffix(name, "-dev") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/Ridecell/ridectl/pkg/cmd/edit"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var keyProviderFlag string
+
+const keyProviderUsage = "(optional) Key provider to use: kms (default), local (~/.ridectl/local-keys.yml) or local:<path-to-keys-file>"
+
+var envelopeFlag string
+
+const envelopeUsage = "(optional) Envelope format for newly encrypted values: v1 (default) or v2, which can be read without Go"
+
+var strictFlag bool
+
+const strictUsage = "(optional) Refuse to decrypt secrets with values not bound to them, such as v1 values. Defaults to strict in .keys.yml"
+
+// strictMode is the --strict flag if given, or else the .keys.yml setting
+// for a manifest.
+func strictMode(cmd *cobra.Command, filename string) (bool, error) {
+	if cmd.Flags().Changed("strict") {
+		return strictFlag, nil
+	}
+	strict, err := edit.FindStrict(filename)
+	if err != nil {
+		return false, errors.Wrapf(err, "error finding strict mode for %s", filename)
+	}
+	return strict, nil
+}
+
+// checkStrict checks the values of a manifest are bound to their secrets
+// before decrypting it, in strict mode.
+func checkStrict(cmd *cobra.Command, filename string, manifest edit.Manifest) error {
+	strict, err := strictMode(cmd, filename)
+	if err != nil || !strict {
+		return err
+	}
+	return manifest.CheckBound()
+}
+
+// getKeyProvider works out the key provider from the --key-provider flag, then
+// the .keys.yml next to the given file, falling back to AWS KMS.
+func getKeyProvider(filename string) (edit.KeyProvider, error) {
+	provider := keyProviderFlag
+	if provider == "" && filename != "" {
+		var err error
+		provider, err = edit.FindKeyProvider(filename)
+		if err != nil {
+			return nil, errors.Wrap(err, "error finding key provider")
+		}
+	}
+
+	switch {
+	case provider == "" || provider == "kms":
+		cfg, err := getAWSConfig("kms-grants", "us-west-1")
+		if err != nil {
+			return nil, errors.Wrapf(err, "error creating AWS session")
+		}
+		// Create an Amazon KMS service client
+		return edit.NewKMSKeyProvider(kms.NewFromConfig(cfg)), nil
+	case provider == "local":
+		return edit.NewLocalKeyProvider(filepath.Join(ridectlHomeDir, "local-keys.yml"))
+	case strings.HasPrefix(provider, "local:"):
+		return edit.NewLocalKeyProvider(strings.TrimPrefix(provider, "local:"))
+	}
+	return nil, errors.Errorf("unknown key provider %s", provider)
+}
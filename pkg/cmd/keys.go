@@ -0,0 +1,190 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Ridecell/ridectl/pkg/cmd/edit"
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysValidateCmd)
+}
+
+/*
+
+An explanation of the keys validate checks:
+
+1. Every .keys.yml is decoded, in either format, and its rules checked for
+   valid globs, regexes and policies.
+2. Every manifest covered by a .keys.yml must get a key from a rule or default.
+3. The key must not be picked by two rules with the same priority.
+4. The key, and the keys recorded in v2 values, must pass the policies of the
+   rules applying to the manifest.
+
+*/
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Work with the .keys.yml files picking the keys manifests are encrypted with",
+}
+
+var keysValidateCmd = &cobra.Command{
+	Use:          "validate [directories]",
+	Short:        "Check .keys.yml files and the keys of the manifests they cover",
+	Long:         "Check .keys.yml files and the keys of the manifests they cover offline, e.g. from a git pre-commit hook. Exits non-zero if any problems are found.",
+	SilenceUsage: true,
+	RunE: func(_ *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		problems := []string{}
+		reported := map[string]bool{}
+		report := func(problem string) {
+			if !reported[problem] {
+				reported[problem] = true
+				problems = append(problems, problem)
+			}
+		}
+
+		keysCount := 0
+		manifestCount := 0
+		for _, dir := range args {
+			keysPaths, err := findKeySettingsFiles(dir)
+			if err != nil {
+				return errors.Wrapf(err, "error finding .keys.yml files in %s", dir)
+			}
+			for _, keysPath := range keysPaths {
+				keysCount++
+				_, err := edit.ReadKeySettings(keysPath)
+				if err != nil {
+					report(err.Error())
+				}
+			}
+
+			filenames, err := findManifestFiles(dir)
+			if err != nil {
+				return errors.Wrapf(err, "error finding manifests in %s", dir)
+			}
+			for _, filename := range filenames {
+				settings, err := edit.LoadKeySettings(filename)
+				if err != nil {
+					report(err.Error())
+					continue
+				}
+				if !settings.Found {
+					continue
+				}
+				manifestCount++
+				for _, problem := range validateManifestKeys(settings, filename) {
+					report(problem)
+				}
+			}
+		}
+
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			return errors.Errorf("%d problems found", len(problems))
+		}
+		pterm.Success.Printf("No problems found in %d .keys.yml files and %d manifests\n", keysCount, manifestCount)
+		return nil
+	},
+}
+
+// findKeySettingsFiles returns the .keys.yml files in a directory tree.
+func findKeySettingsFiles(dir string) ([]string, error) {
+	keysPaths := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && d.Name() == ".keys.yml" {
+			keysPaths = append(keysPaths, path)
+		}
+		return nil
+	})
+	return keysPaths, err
+}
+
+// validateManifestKeys checks the key .keys.yml picks for a manifest, and the
+// keys recorded in its values.
+func validateManifestKeys(settings *edit.KeySettings, filename string) []string {
+	problems := []string{}
+	keyId := settings.KeyId(filename)
+	if keyId == "" {
+		problems = append(problems, fmt.Sprintf("%s: no rule or default in %s picks a key", filename, settings.Path))
+	} else {
+		err := settings.CheckKey(nil, filename, keyId)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	keyRules := []*edit.KeyRule{}
+	for _, rule := range settings.Applying(filename) {
+		if rule.Key != "" {
+			keyRules = append(keyRules, rule)
+		}
+	}
+	if len(keyRules) > 1 && keyRules[0].Priority == keyRules[1].Priority && keyRules[0].Key != keyRules[1].Key {
+		problems = append(problems, fmt.Sprintf("%s: %s and %s in %s both pick a key with priority %d", filename, keyRules[0], keyRules[1], settings.Path, keyRules[0].Priority))
+	}
+
+	// v2 values record their key, others can only be checked by decrypting.
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return append(problems, fmt.Sprintf("%s: %v", filename, err))
+	}
+	manifest, _ := edit.LintManifest(filename, raw)
+	for _, obj := range manifest {
+		if obj.Kind != "EncryptedSecret" {
+			continue
+		}
+		keys := []string{}
+		for key := range obj.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			valueKeyId := edit.EnvelopeKeyId(obj.Data[key])
+			if valueKeyId == "" {
+				continue
+			}
+			err := settings.CheckKey(nil, filename, valueKeyId)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s:%d: %s %s: %v", filename, obj.KeyLine(key), edit.ObjectName(obj), key, err))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Ridecell/ridectl/pkg/cmd/edit"
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
+
+var lintDecryptFlag bool
+
+func init() {
+	lintCmd.Flags().BoolVar(&lintDecryptFlag, "decrypt", false, "(optional) Decrypt v1 values to check their key ID, which needs KMS access")
+	lintCmd.Flags().StringVar(&keyProviderFlag, "key-provider", "", keyProviderUsage)
+	lintCmd.Flags().BoolVar(&strictFlag, "strict", false, "(optional) Report every value not bound to its secret, such as v1 values. Defaults to strict in .keys.yml")
+}
+
+/*
+
+An explanation of the lint checks:
+
+1. Every document is decoded strictly with the registered scheme, reporting
+   unknown or invalid fields, e.g. in SummonPlatform specs.
+2. DecryptedSecrets must never be committed.
+3. Every EncryptedSecret value must be in crypto format.
+4. Values must be encrypted with the key .keys.yml picks for the file. This is
+   recorded in v2 values. v1 values, and v2 values recording a key ARN rather
+   than an alias, are only checked with --decrypt.
+5. No two objects of the same kind in a file may have the same namespace/name.
+6. Values not bound to their secret, v1 or secretbox, are reported in secrets
+   which also have bound v2 values, as they may have been swapped in. In
+   strict mode every unbound value is reported.
+
+*/
+
+var lintCmd = &cobra.Command{
+	Use:          "lint [flags] [files or directories]",
+	Short:        "Check instance manifests for problems",
+	Long:         "Check instance manifests offline, e.g. from a git pre-commit hook. Exits non-zero if any problems are found.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		filenames := []string{}
+		for _, arg := range args {
+			info, err := os.Stat(arg)
+			if err != nil {
+				return errors.Wrapf(err, "error reading %s", arg)
+			}
+			if !info.IsDir() {
+				filenames = append(filenames, arg)
+				continue
+			}
+			found, err := findManifestFiles(arg)
+			if err != nil {
+				return errors.Wrapf(err, "error finding manifests in %s", arg)
+			}
+			filenames = append(filenames, found...)
+		}
+
+		problems := []edit.Problem{}
+		keyProviders := map[string]edit.KeyProvider{}
+		for _, filename := range filenames {
+			raw, err := os.ReadFile(filename)
+			if err != nil {
+				return errors.Wrapf(err, "error reading %s", filename)
+			}
+			manifest, fileProblems := edit.LintManifest(filename, raw)
+			problems = append(problems, fileProblems...)
+
+			// Files for different clusters can define the same objects.
+			seen := map[string]edit.Problem{}
+			for _, obj := range manifest {
+				if obj.Meta == nil {
+					continue
+				}
+				kind := obj.Object.GetObjectKind().GroupVersionKind().Kind
+				id := fmt.Sprintf("%s %s", kind, edit.ObjectName(obj))
+				if first, ok := seen[id]; ok {
+					problems = append(problems, edit.Problem{
+						File:    filename,
+						Line:    obj.Line,
+						Rule:    edit.RuleDuplicate,
+						Object:  edit.ObjectName(obj),
+						Message: fmt.Sprintf("%s already defined at %s:%d", kind, first.File, first.Line),
+					})
+					continue
+				}
+				seen[id] = edit.Problem{File: filename, Line: obj.Line}
+			}
+
+			unboundProblems, err := lintUnbound(cmd, filename, manifest)
+			if err != nil {
+				return err
+			}
+			problems = append(problems, unboundProblems...)
+
+			keyProblems, err := lintKeyIds(filename, manifest, keyProviders)
+			if err != nil {
+				return err
+			}
+			problems = append(problems, keyProblems...)
+		}
+
+		sort.SliceStable(problems, func(i, j int) bool {
+			if problems[i].File != problems[j].File {
+				return problems[i].File < problems[j].File
+			}
+			return problems[i].Line < problems[j].Line
+		})
+
+		err := printOutput(lintResult(problems))
+		if err != nil {
+			return err
+		}
+
+		if len(problems) > 0 {
+			return errors.Errorf("%d problems found in %d files", len(problems), len(filenames))
+		}
+		if !structuredOutput() {
+			pterm.Success.Printf("No problems found in %d files\n", len(filenames))
+		}
+		return nil
+	},
+}
+
+// lintResult is the output of lint, a list of problems.
+type lintResult []edit.Problem
+
+// Names are the files with problems.
+func (r lintResult) Names() []string {
+	names := []string{}
+	for _, p := range r {
+		if !slices.Contains(names, p.File) {
+			names = append(names, p.File)
+		}
+	}
+	return names
+}
+
+func (r lintResult) PrintTable() {
+	for _, p := range r {
+		target := p.Object
+		if p.Key != "" {
+			target += " " + p.Key
+		}
+		if target != "" {
+			target += ": "
+		}
+		fmt.Printf("%s:%d: [%s] %s%s\n", p.File, p.Line, p.Rule, target, p.Message)
+	}
+}
+
+// lintUnbound reports the encrypted values of EncryptedSecrets which are not
+// bound to them, in strict mode or when other values are.
+func lintUnbound(cmd *cobra.Command, filename string, manifest edit.Manifest) ([]edit.Problem, error) {
+	problems := []edit.Problem{}
+	strict, err := strictMode(cmd, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, obj := range manifest {
+		if obj.Kind != "EncryptedSecret" {
+			continue
+		}
+		unbound := obj.UnboundKeys()
+		message := "value is not bound to the secret, re-encrypt it with ridectl edit -r --envelope v2"
+		if !strict {
+			if len(unbound) == len(obj.Data) {
+				continue
+			}
+			message = "value is not bound to the secret while others are, check it was not swapped in, then re-encrypt it with ridectl edit -r --envelope v2"
+		}
+		for _, key := range unbound {
+			// Values not in crypto format are already reported.
+			if !edit.IsPayload(obj.Data[key]) {
+				continue
+			}
+			problems = append(problems, edit.Problem{
+				File:    filename,
+				Line:    obj.KeyLine(key),
+				Rule:    edit.RuleUnbound,
+				Object:  edit.ObjectName(obj),
+				Key:     key,
+				Message: message,
+			})
+		}
+	}
+	return problems, nil
+}
+
+// lintKeyIds checks the EncryptedSecrets in a manifest are encrypted with the
+// key .keys.yml picks for it.
+func lintKeyIds(filename string, manifest edit.Manifest, keyProviders map[string]edit.KeyProvider) ([]edit.Problem, error) {
+	problems := []edit.Problem{}
+	expected, err := edit.FindKeyId(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error finding key ID for %s", filename)
+	}
+	if expected == "" {
+		return problems, nil
+	}
+
+	// Manifests in the same directory share a .keys.yml, and so a key provider.
+	dir := filepath.Dir(filename)
+	getProvider := func() (edit.KeyProvider, error) {
+		keyProvider, ok := keyProviders[dir]
+		if !ok {
+			var err error
+			keyProvider, err = getKeyProvider(filename)
+			if err != nil {
+				return nil, err
+			}
+			keyProviders[dir] = keyProvider
+		}
+		return keyProvider, nil
+	}
+	aliases := map[string][]string{}
+
+	for _, obj := range manifest {
+		if obj.Kind != "EncryptedSecret" {
+			continue
+		}
+		keys := []string{}
+		for key := range obj.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		unknown := false
+		for _, key := range keys {
+			value := obj.Data[key]
+			if !edit.IsPayload(value) {
+				continue
+			}
+			keyId := edit.EnvelopeKeyId(value)
+			matches := keyId == expected
+			switch {
+			case keyId == "":
+				unknown = true
+				continue
+			case !matches && !strings.HasPrefix(keyId, "alias/"):
+				// A key ARN, as older versions recorded, needs its aliases.
+				if !lintDecryptFlag {
+					continue
+				}
+				keyProvider, err := getProvider()
+				if err != nil {
+					return nil, err
+				}
+				matches = isKey(keyProvider, keyId, expected, aliases)
+			}
+			if !matches {
+				problems = append(problems, edit.Problem{
+					File:    filename,
+					Line:    obj.KeyLine(key),
+					Rule:    edit.RuleKeyMismatch,
+					Object:  edit.ObjectName(obj),
+					Key:     key,
+					Message: fmt.Sprintf("encrypted with %s, .keys.yml expects %s", keyId, expected),
+				})
+			}
+		}
+		if !unknown || !lintDecryptFlag {
+			continue
+		}
+
+		keyProvider, err := getProvider()
+		if err != nil {
+			return nil, err
+		}
+		err = obj.Decrypt(keyProvider, true)
+		if err != nil {
+			problems = append(problems, edit.Problem{
+				File:    filename,
+				Line:    obj.Line,
+				Rule:    edit.RuleDecrypt,
+				Object:  edit.ObjectName(obj),
+				Message: err.Error(),
+			})
+			continue
+		}
+		for _, keyId := range obj.KeyIds {
+			if !isKey(keyProvider, keyId, expected, aliases) {
+				problems = append(problems, edit.Problem{
+					File:    filename,
+					Line:    obj.Line,
+					Rule:    edit.RuleKeyMismatch,
+					Object:  edit.ObjectName(obj),
+					Message: fmt.Sprintf("encrypted with %s, .keys.yml expects %s", keyId, expected),
+				})
+			}
+		}
+	}
+	return problems, nil
+}
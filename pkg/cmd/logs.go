@@ -0,0 +1,238 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Ridecell/ridectl/pkg/kubernetes"
+	"github.com/Ridecell/ridectl/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1 "k8s.io/api/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+/*
+
+An explanation of logs:
+
+1. The instance is found like for the other commands, and its pods are listed
+   with the same labels as shell and restart use for the component.
+2. The logs of every pod, and of the selected containers in it, are streamed
+   concurrently. Each line is prefixed with the pod name, and the container
+   name with --all-containers, in a color per pod.
+3. --grep only prints the lines matching a regular expression, --since and
+   --previous are passed to the API like for kubectl logs.
+4. With --follow, streams are read until they end or ridectl is interrupted.
+   Pods started after the command are not picked up.
+
+*/
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+}
+
+var logsFollowFlag bool
+var logsSinceFlag time.Duration
+var logsPreviousFlag bool
+var logsGrepFlag string
+var logsContainerFlag string
+var logsAllContainersFlag bool
+
+func init() {
+	logsCmd.Flags().BoolVarP(&logsFollowFlag, "follow", "f", false, "(optional) keep streaming new log lines")
+	logsCmd.Flags().DurationVar(&logsSinceFlag, "since", 0, "(optional) only show lines newer than this, e.g. 10m")
+	logsCmd.Flags().BoolVarP(&logsPreviousFlag, "previous", "p", false, "(optional) show the logs of the previous, crashed containers")
+	logsCmd.Flags().StringVar(&logsGrepFlag, "grep", "", "(optional) only show lines matching this regular expression")
+	logsCmd.Flags().StringVarP(&logsContainerFlag, "container", "c", "", "(optional) container to show, defaults to the first container of each pod")
+	logsCmd.Flags().BoolVar(&logsAllContainersFlag, "all-containers", false, "(optional) show the logs of every container of each pod")
+}
+
+// Colors the pod prefixes cycle through.
+var logsColors = []pterm.Color{pterm.FgCyan, pterm.FgGreen, pterm.FgMagenta, pterm.FgYellow, pterm.FgBlue, pterm.FgLightRed}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [flags] <tenant_name> [component]",
+	Short: "Stream logs from all pods of a Summon instance or microservice component",
+	Long: "Streams the logs of every pod of a component, web by default, prefixed with the pod name\n" +
+		"For summon instances: logs <tenant>-<env> [component]                   -- e.g. ridectl logs darwin-qa celeryd -f\n" +
+		"For microservices: logs svc-<region>-<env>-<microservice> [component]   -- e.g. ridectl logs svc-us-master-dispatch --since 1h --grep ERROR",
+	SilenceUsage: true,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("tenant name argument is required")
+		}
+		if len(args) > 2 {
+			return fmt.Errorf("too many arguments")
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if logsContainerFlag != "" && logsAllContainersFlag {
+			return fmt.Errorf("--container and --all-containers can't be used together")
+		}
+		utils.CheckTshLogin()
+		return nil
+	},
+	RunE: func(_ *cobra.Command, args []string) error {
+		var grep *regexp.Regexp
+		if logsGrepFlag != "" {
+			var err error
+			grep, err = regexp.Compile(logsGrepFlag)
+			if err != nil {
+				return errors.Wrap(err, "invalid --grep")
+			}
+		}
+		component := "web"
+		if len(args) > 1 {
+			component = args[1]
+		}
+
+		target, kubeObj, exist := utils.DoesInstanceExist(args[0], inCluster, kubeconfigFlag)
+		if !exist {
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		pods := &corev1.PodList{}
+		err := kubeObj.Client.List(ctx, pods, &client.ListOptions{
+			Namespace:     target.Namespace,
+			LabelSelector: labels.SelectorFromSet(kubernetes.PodLabels(target, component)),
+		})
+		if err != nil {
+			return errors.Wrap(err, "error listing pods")
+		}
+		if len(pods.Items) < 1 {
+			return errors.Errorf("no %s pods found for %s in %s", component, target.Name, kubeObj.Context)
+		}
+		sort.Slice(pods.Items, func(i, j int) bool {
+			return pods.Items[i].Name < pods.Items[j].Name
+		})
+
+		kubeconfig := utils.GetKubeconfig(kubeconfigFlag)
+		cs, err := kubernetes.NewClientset(*kubeconfig, kubeObj.Context, inCluster)
+		if err != nil {
+			return errors.Wrap(err, "error getting cluster client")
+		}
+
+		options := corev1.PodLogOptions{Follow: logsFollowFlag, Previous: logsPreviousFlag}
+		if logsSinceFlag > 0 {
+			seconds := int64(logsSinceFlag.Seconds())
+			options.SinceSeconds = &seconds
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		failed := 0
+		streams := 0
+		for i, pod := range pods.Items {
+			containers, err := logsContainers(pod)
+			if err != nil {
+				pterm.Warning.Println(err)
+				continue
+			}
+			for _, container := range containers {
+				prefix := pod.Name
+				if logsAllContainersFlag {
+					prefix += "/" + container
+				}
+				prefix = logsColors[i%len(logsColors)].Sprintf("[%s]", prefix)
+				streams++
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					containerOptions := options
+					containerOptions.Container = container
+					err := streamLogs(ctx, cs, pod, containerOptions, prefix, grep, &mu)
+					if err != nil {
+						mu.Lock()
+						defer mu.Unlock()
+						failed++
+						pterm.Warning.Printf("error streaming logs of %s/%s: %v\n", pod.Name, container, err)
+					}
+				}()
+			}
+		}
+		wg.Wait()
+		if failed == streams {
+			return errors.Errorf("no logs could be read for %s %s", target.Name, component)
+		}
+		return nil
+	},
+}
+
+// logsContainers are the containers of a pod to show the logs of.
+func logsContainers(pod corev1.Pod) ([]string, error) {
+	containers := []string{}
+	for _, container := range pod.Spec.Containers {
+		containers = append(containers, container.Name)
+	}
+	switch {
+	case logsAllContainersFlag:
+		return containers, nil
+	case logsContainerFlag != "":
+		for _, container := range containers {
+			if container == logsContainerFlag {
+				return []string{container}, nil
+			}
+		}
+		return nil, errors.Errorf("pod %s has no container %s, it has: %v", pod.Name, logsContainerFlag, containers)
+	case len(containers) > 0:
+		return containers[:1], nil
+	}
+	return nil, errors.Errorf("pod %s has no containers", pod.Name)
+}
+
+// streamLogs prints the log lines of a container which match grep, with a
+// prefix. mu serializes lines from concurrent streams.
+func streamLogs(ctx context.Context, cs clientset.Interface, pod corev1.Pod, options corev1.PodLogOptions, prefix string, grep *regexp.Regexp, mu *sync.Mutex) error {
+	stream, err := cs.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &options).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stream.Close() }()
+	return printLogLines(stream, prefix, grep, mu)
+}
+
+func printLogLines(r io.Reader, prefix string, grep *regexp.Regexp, mu *sync.Mutex) error {
+	scanner := bufio.NewScanner(r)
+	// Allow long lines, e.g. JSON logs with stack traces.
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if grep != nil && !grep.MatchString(line) {
+			continue
+		}
+		mu.Lock()
+		fmt.Printf("%s %s\n", prefix, line)
+		mu.Unlock()
+	}
+	return scanner.Err()
+}
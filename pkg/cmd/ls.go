@@ -0,0 +1,114 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Ridecell/ridectl/pkg/kubernetes"
+	"github.com/Ridecell/ridectl/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+/*
+
+An explanation of ls:
+
+Every kubeconfig context which passes the same checks as the instance lookup
+of the other commands is listed concurrently, for SummonPlatforms in all
+namespaces and microservice web Deployments. Instances are only shown for
+clusters the other commands would look for them in, so everything listed can
+be passed to them. An instance found in more than one cluster is listed once
+per context. Clusters which can't be listed, e.g. for lack of permissions, are
+warned about and skipped.
+
+*/
+
+func init() {
+	rootCmd.AddCommand(lsCmd)
+}
+
+var lsEnvFlag string
+var lsRegionFlag string
+var lsTypeFlag string
+
+func init() {
+	lsCmd.Flags().StringVar(&lsEnvFlag, "env", "", "(optional) only list instances of an environment, e.g. qa")
+	lsCmd.Flags().StringVar(&lsRegionFlag, "region", "", "(optional) only list instances of a region, e.g. us")
+	lsCmd.Flags().StringVar(&lsTypeFlag, "type", "", "(optional) only list instances of a type: summon or microservice")
+}
+
+var lsCmd = &cobra.Command{
+	Use:   "ls [flags]",
+	Short: "Lists Summon Instances and microservices across all clusters",
+	Long: "Lists Summon Instances and microservices with their environment, region, cluster context, version and status\n" +
+		"e.g. ridectl ls --env qa --type summon\n" +
+		"     ridectl ls --type microservice -o name",
+	SilenceUsage: true,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("too many arguments")
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if lsTypeFlag != "" && lsTypeFlag != "summon" && lsTypeFlag != "microservice" {
+			return errors.Errorf("invalid --type %s, expected one of: summon, microservice", lsTypeFlag)
+		}
+		utils.CheckTshLogin()
+		return nil
+	},
+	RunE: func(_ *cobra.Command, args []string) error {
+		kubeconfig := utils.GetKubeconfig(kubeconfigFlag)
+		filter := kubernetes.ListFilter{Env: lsEnvFlag, Region: lsRegionFlag, Type: lsTypeFlag}
+		instances, err := kubernetes.ListInstances(*kubeconfig, filter, inCluster)
+		if err != nil {
+			return err
+		}
+		return printOutput(lsResult(instances))
+	},
+}
+
+// lsResult is the output of ls.
+type lsResult []kubernetes.Instance
+
+// Names are the instance names, once each.
+func (r lsResult) Names() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, instance := range r {
+		if !seen[instance.Name] {
+			seen[instance.Name] = true
+			names = append(names, instance.Name)
+		}
+	}
+	return names
+}
+
+func (r lsResult) PrintTable() {
+	if len(r) == 0 {
+		pterm.Info.Println("No instances found")
+		return
+	}
+	data := pterm.TableData{{"NAME", "TYPE", "ENV", "REGION", "CONTEXT", "VERSION", "STATUS"}}
+	for _, instance := range r {
+		data = append(data, []string{instance.Name, instance.Type, instance.Env, instance.Region, instance.Context, instance.Version, instance.Status})
+	}
+	_ = pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+	"sigs.k8s.io/yaml"
+)
+
+/*
+
+Commands which report data print it with printOutput, in the format picked by
+the global -o flag:
+
+  table  the human readable form, the default
+  json   the result as indented JSON
+  yaml   the result as YAML, with the same field names as JSON
+  name   one name per line, e.g. for xargs
+
+For json, yaml and name, all other messages go to stderr so stdout only has
+the data. The results are plain structs with json tags, and are a stable
+interface for scripts: fields can be added, but not renamed or removed.
+
+*/
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputName  = "name"
+)
+
+var outputFlag string
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", outputTable, "(optional) Output format: table, json, yaml or name")
+}
+
+// Result is the data a command reports.
+type Result interface {
+	// Names are printed one per line for -o name.
+	Names() []string
+	// PrintTable prints the human readable form.
+	PrintTable()
+}
+
+// checkOutputFormat validates -o, and moves messages to stderr for the
+// machine readable formats.
+func checkOutputFormat() error {
+	switch outputFlag {
+	case "text":
+		// lint took -o text before there was a global flag.
+		outputFlag = outputTable
+	case outputTable:
+	case outputJSON, outputYAML, outputName:
+		pterm.SetDefaultOutput(os.Stderr)
+	default:
+		return errors.Errorf("unknown output format %s, expected table, json, yaml or name", outputFlag)
+	}
+	return nil
+}
+
+// structuredOutput is true when stdout is for data only.
+func structuredOutput() bool {
+	return outputFlag != outputTable
+}
+
+// printOutput prints a result in the -o format.
+func printOutput(result Result) error {
+	switch outputFlag {
+	case outputJSON:
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error encoding output")
+		}
+		fmt.Println(string(out))
+	case outputYAML:
+		out, err := yaml.Marshal(result)
+		if err != nil {
+			return errors.Wrap(err, "error encoding output")
+		}
+		fmt.Print(string(out))
+	case outputName:
+		for _, name := range result.Names() {
+			fmt.Println(name)
+		}
+	default:
+		result.PrintTable()
+	}
+	return nil
+}
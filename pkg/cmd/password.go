@@ -22,8 +22,8 @@ import (
 	"os"
 	"strings"
 
+	"github.com/Ridecell/ridectl/pkg/audit"
 	"github.com/Ridecell/ridectl/pkg/utils"
-	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
@@ -37,6 +37,14 @@ func init() {
 	rootCmd.AddCommand(passwordCmd)
 }
 
+var passwordSecretFlag string
+var passwordReadonlySecretFlag string
+
+func init() {
+	passwordCmd.Flags().StringVar(&passwordSecretFlag, "secret", "", "(optional) secret to show for summon instances: django or postgresql")
+	passwordCmd.Flags().StringVar(&passwordReadonlySecretFlag, "readonly-secret", "", "(optional) name of the postgresql readonly user secret, if there is more than one")
+}
+
 var passwordCmd = &cobra.Command{
 	Use:   "password [flags] <tenant_name>",
 	Short: "Gets dispatcher/postgres readonly user password/connection details for a Summon Instance",
@@ -74,14 +82,9 @@ var passwordCmd = &cobra.Command{
 		if target.Type == "summon" {
 			secretTypes := []string{"django", "postgresql"}
 
-			secretPrompt := promptui.Select{
-				Label: "Select secret",
-				Items: secretTypes,
-			}
-
-			_, secretType, err = secretPrompt.Run()
+			secretType, err = promptSelect("Select secret", secretTypes, "--secret", passwordSecretFlag)
 			if err != nil {
-				return errors.Wrapf(err, "Prompt failed")
+				return err
 			}
 		}
 
@@ -95,8 +98,13 @@ var passwordCmd = &cobra.Command{
 				return errors.Wrapf(err, "error getting secret for instance %s", args[0])
 			}
 
-			pterm.Success.Printf("Password for %s: %s\n", args[0], string(secret.Data["password"]))
-			pterm.Warning.Printf("If someone has changed or reset the password manually, then above password will not work.\n")
+			auditLog(audit.Record{Command: "password", Instance: args[0], Namespace: target.Namespace, Context: kubeObj.Context, Details: map[string]string{"secret": secret.Name}}, kubeObj.Client)
+			return printOutput(&passwordResult{
+				Instance: args[0],
+				Type:     secretType,
+				Secret:   secret.Name,
+				Password: string(secret.Data["password"]),
+			})
 
 		case "postgresql":
 			// get a list of secrets which have readonly in their name
@@ -119,13 +127,9 @@ var passwordCmd = &cobra.Command{
 				return errors.Errorf("no readonly secrets found for instance %s", args[0])
 			}
 			// prompt user to select a readonly secret
-			prompt := promptui.Select{
-				Label: "Select secret",
-				Items: readOnlysecrets,
-			}
-			_, result, err := prompt.Run()
+			result, err := promptSelect("Select secret", readOnlysecrets, "--readonly-secret", passwordReadonlySecretFlag)
 			if err != nil {
-				return errors.Wrapf(err, "Prompt failed")
+				return err
 			}
 			// get the password from the selected secret
 			err = kubeObj.Client.Get(ctx, types.NamespacedName{Name: result, Namespace: target.Namespace}, secret)
@@ -133,19 +137,54 @@ var passwordCmd = &cobra.Command{
 				return errors.Wrapf(err, "error getting secret for instance %s", args[0])
 			}
 
-			pterm.Success.Printf("Readonly User Connection Details\n")
-			pterm.Success.Prefix = pterm.Prefix{
-				Text: "",
-			}
-			pterm.Success.Printf("Database Type: Postgres\n") // Hard code-y
-			pterm.Success.Printf("Database Host: %s\n", string(secret.Data["host"]))
-			pterm.Success.Printf("Database Port: %s\n", string(secret.Data["port"]))
-			pterm.Success.Printf("Database Name: %s\n", string(secret.Data["dbname"]))
-			pterm.Success.Printf("Database Username: %s\n", string(secret.Data["username"]))
-			pterm.Success.Printf("Database Password: %s\n", string(secret.Data["password"]))
-
+			auditLog(audit.Record{Command: "password", Instance: args[0], Namespace: target.Namespace, Context: kubeObj.Context, Details: map[string]string{"secret": secret.Name}}, kubeObj.Client)
+			return printOutput(&passwordResult{
+				Instance: args[0],
+				Type:     secretType,
+				Secret:   secret.Name,
+				Password: string(secret.Data["password"]),
+				Host:     string(secret.Data["host"]),
+				Port:     string(secret.Data["port"]),
+				Database: string(secret.Data["dbname"]),
+				Username: string(secret.Data["username"]),
+			})
 		}
 
 		return nil
 	},
 }
+
+// passwordResult is the output of password. Type is django or postgresql,
+// the connection details are only set for postgresql.
+type passwordResult struct {
+	Instance string `json:"instance"`
+	Type     string `json:"type"`
+	Secret   string `json:"secret"`
+	Password string `json:"password"`
+	Host     string `json:"host,omitempty"`
+	Port     string `json:"port,omitempty"`
+	Database string `json:"database,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func (r *passwordResult) Names() []string {
+	return []string{r.Secret}
+}
+
+func (r *passwordResult) PrintTable() {
+	if r.Type == "django" {
+		pterm.Success.Printf("Password for %s: %s\n", r.Instance, r.Password)
+		pterm.Warning.Printf("If someone has changed or reset the password manually, then above password will not work.\n")
+		return
+	}
+	pterm.Success.Printf("Readonly User Connection Details\n")
+	pterm.Success.Prefix = pterm.Prefix{
+		Text: "",
+	}
+	pterm.Success.Printf("Database Type: Postgres\n") // Hard code-y
+	pterm.Success.Printf("Database Host: %s\n", r.Host)
+	pterm.Success.Printf("Database Port: %s\n", r.Port)
+	pterm.Success.Printf("Database Name: %s\n", r.Database)
+	pterm.Success.Printf("Database Username: %s\n", r.Username)
+	pterm.Success.Printf("Database Password: %s\n", r.Password)
+}
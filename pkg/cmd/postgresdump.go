@@ -106,9 +106,26 @@ var postgresdumpCMD = &cobra.Command{
 		if err != nil {
 			return errors.Wrap(err, "failed to create postgresdump instance")
 		}
-		// Do not change the following output format, kubernetes-microservices deploy workflow uses it in Backup DB step.
-		pterm.Info.Printf("Created postgresdump kind with Name: %s Namespace: %s .\n", instanceName, postgresdumpObj.Namespace)
-		pterm.Info.Printf("You can check status of DB backup using 'ridectl status' command \n")
-		return nil
+		return printOutput(&postgresdumpResult{
+			Name:      instanceName,
+			Namespace: postgresdumpObj.Namespace,
+		})
 	},
 }
+
+// postgresdumpResult is the output of postgresdump, the PostgresDump created.
+type postgresdumpResult struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+func (r *postgresdumpResult) Names() []string {
+	return []string{r.Name}
+}
+
+func (r *postgresdumpResult) PrintTable() {
+	// Do not change the following output format, kubernetes-microservices deploy workflow uses it in Backup DB step.
+	// New automation should use -o json instead.
+	pterm.Info.Printf("Created postgresdump kind with Name: %s Namespace: %s .\n", r.Name, r.Namespace)
+	pterm.Info.Printf("You can check status of DB backup using 'ridectl status' command \n")
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Ridecell/ridectl/pkg/kubernetes"
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+)
+
+/*
+
+Every prompt has a flag giving its answer, so commands can be scripted. With
+--non-interactive, which is the default on Github actions runners, a missing
+answer is an error instead of a prompt. --yes answers confirmations.
+
+Select flags take the option in lower case with dashes for spaces, or any
+unique prefix of it, e.g. "db-backup" or "summon" for "Summon Platform".
+
+*/
+
+var nonInteractiveFlag bool
+var yesFlag bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&nonInteractiveFlag, "non-interactive", os.Getenv("GITHUB_ACTIONS") == "true", "(optional) fail instead of prompting when a flag is missing")
+	rootCmd.PersistentFlags().BoolVarP(&yesFlag, "yes", "y", false, "(optional) answer yes to confirmations")
+}
+
+// optionName is how a select option is given as a flag value.
+func optionName(item string) string {
+	return strings.ReplaceAll(strings.ToLower(item), " ", "-")
+}
+
+// promptSelect returns the item matching the flag value, or prompts for one.
+// flagName is how the value is given, e.g. "--mode", for error messages.
+// A single item is picked without prompting in non-interactive mode.
+func promptSelect(label string, items []string, flagName string, value string) (string, error) {
+	if value != "" {
+		value = optionName(value)
+		matches := []string{}
+		for _, item := range items {
+			if optionName(item) == value {
+				return item, nil
+			}
+			if strings.HasPrefix(optionName(item), value) {
+				matches = append(matches, item)
+			}
+		}
+		if len(matches) == 1 {
+			return matches[0], nil
+		}
+		names := []string{}
+		for _, item := range items {
+			names = append(names, optionName(item))
+		}
+		return "", errors.Errorf("invalid %s %s, expected one of: %s", flagName, value, strings.Join(names, ", "))
+	}
+	if nonInteractiveFlag {
+		if len(items) == 1 {
+			return items[0], nil
+		}
+		return "", errors.Errorf("%s is required with --non-interactive", flagName)
+	}
+
+	prompt := promptui.Select{
+		Label: label,
+		Items: items,
+	}
+	_, result, err := prompt.Run()
+	if err != nil {
+		return "", errors.Wrapf(err, "Prompt failed")
+	}
+	return result, nil
+}
+
+// promptInput returns the flag value after validating it, or prompts for one.
+func promptInput(label string, validate promptui.ValidateFunc, flagName string, value string) (string, error) {
+	if value != "" {
+		if validate != nil {
+			err := validate(value)
+			if err != nil {
+				return "", errors.Wrapf(err, "invalid %s", flagName)
+			}
+		}
+		return value, nil
+	}
+	if nonInteractiveFlag {
+		return "", errors.Errorf("%s is required with --non-interactive", flagName)
+	}
+
+	prompt := promptui.Prompt{
+		Label:    label,
+		Validate: validate,
+	}
+	result, err := prompt.Run()
+	if err != nil {
+		return "", errors.Wrapf(err, "Prompt failed")
+	}
+	return result, nil
+}
+
+// promptConfirm asks a yes/no question, answered yes by --yes. In
+// non-interactive mode it errors unless --yes is given.
+func promptConfirm(label string) (bool, error) {
+	if yesFlag {
+		return true, nil
+	}
+	if nonInteractiveFlag {
+		return false, errors.Errorf("%s: pass --yes to confirm with --non-interactive", label)
+	}
+	prompt := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}
+	goAhead, _ := prompt.Run()
+	return goAhead == "y", nil
+}
+
+// selectContext prompts for which context to use for an instance found in
+// more than one.
+func selectContext(subject kubernetes.Subject, contexts []string) (string, error) {
+	return promptSelect(fmt.Sprintf("%s was found in more than one context, select one", subject.Name), contexts, "--context", "")
+}
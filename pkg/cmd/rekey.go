@@ -0,0 +1,263 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Ridecell/ridectl/pkg/cmd/edit"
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(rekeyCmd)
+}
+
+var rekeyFromFlag string
+var rekeyToFlag string
+var rekeyCheckFlag bool
+var rekeyUpdateKeysFlag bool
+
+func init() {
+	rekeyCmd.Flags().StringVar(&rekeyFromFlag, "from", "", "KMS key ID / key alias to rotate away from")
+	rekeyCmd.Flags().StringVar(&rekeyToFlag, "to", "", "KMS key ID / key alias to re-encrypt with")
+	rekeyCmd.Flags().BoolVar(&rekeyCheckFlag, "check", false, "(optional) Only list the files still using the old key")
+	rekeyCmd.Flags().BoolVar(&rekeyUpdateKeysFlag, "update-keys", false, "(optional) Also point .keys.yml entries using the old key at the new key")
+	rekeyCmd.Flags().StringVar(&keyProviderFlag, "key-provider", "", keyProviderUsage)
+	rekeyCmd.Flags().StringVar(&envelopeFlag, "envelope", edit.EnvelopeV1, envelopeUsage)
+	rekeyCmd.Flags().BoolVar(&strictFlag, "strict", false, strictUsage)
+}
+
+/*
+
+An explanation of the rekey process:
+
+1. The directory is walked for manifest files.
+2. Each manifest is decrypted, recording the keys used by its values.
+3. Secrets with any value using the old key are re-encrypted with the new key
+   and a new data key. Other secrets keep their existing ciphertext.
+4. The manifest is written back.
+5. Optionally, .keys.yml entries using the old key are changed to the new key.
+
+*/
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey --from <kms-key-alias> --to <kms-key-alias> [--check] [--update-keys] <dir>",
+	Short: "Re-encrypt all manifests using one KMS key with another",
+	Long:  `Walk a directory of instance manifests and re-encrypt every secret using the --from key with the --to key`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			pterm.Error.Println("a single directory argument is required")
+			os.Exit(1)
+		}
+		if rekeyFromFlag == "" || (rekeyToFlag == "" && !rekeyCheckFlag) {
+			pterm.Error.Println("--from and --to are required")
+			os.Exit(1)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := edit.SetEnvelope(envelopeFlag)
+		if err != nil {
+			return err
+		}
+		filenames, err := findManifestFiles(args[0])
+		if err != nil {
+			return errors.Wrapf(err, "error finding manifests in %s", args[0])
+		}
+
+		keyProviders := map[string]edit.KeyProvider{}
+		aliases := map[string][]string{}
+		keysFiles := map[string]string{}
+		changed, pending, failed := 0, 0, 0
+		for _, filename := range filenames {
+			// Manifests in the same directory share a .keys.yml, and so a key provider.
+			dir := filepath.Dir(filename)
+			keysFiles[dir] = filename
+			keyProvider, ok := keyProviders[dir]
+			if !ok {
+				keyProvider, err = getKeyProvider(filename)
+				if err != nil {
+					return err
+				}
+				keyProviders[dir] = keyProvider
+			}
+
+			manifest, usesOld, err := loadRekeyManifest(cmd, filename, keyProvider, aliases)
+			if err != nil {
+				pterm.Error.Printf("%s: %v\n", filename, err)
+				failed++
+				continue
+			}
+			if !usesOld {
+				continue
+			}
+			if rekeyCheckFlag {
+				pterm.Warning.Printf("%s: uses %s\n", filename, rekeyFromFlag)
+				pending++
+				continue
+			}
+
+			err = rekeyManifest(filename, manifest, keyProvider, aliases)
+			if err != nil {
+				pterm.Error.Printf("%s: %v\n", filename, err)
+				failed++
+				continue
+			}
+			pterm.Success.Printf("%s: re-encrypted with %s\n", filename, rekeyToFlag)
+			changed++
+		}
+
+		// Check or update the key settings covering the manifests, which may be
+		// shared by several directories.
+		seenKeysPaths := map[string]bool{}
+		for _, dir := range sortedKeys(keysFiles) {
+			if rekeyCheckFlag || !rekeyUpdateKeysFlag {
+				keysPath, names, err := edit.FindKeySettingsUsing(keysFiles[dir], rekeyFromFlag)
+				if err != nil {
+					return err
+				}
+				if len(names) > 0 && !seenKeysPaths[keysPath] {
+					pterm.Warning.Printf("%s: %s still set to %s\n", keysPath, strings.Join(names, ", "), rekeyFromFlag)
+				}
+				seenKeysPaths[keysPath] = true
+				continue
+			}
+			keysPath, count, err := edit.UpdateKeySettings(keysFiles[dir], rekeyFromFlag, rekeyToFlag)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				pterm.Success.Printf("%s: %d entries set to %s\n", keysPath, count, rekeyToFlag)
+			}
+		}
+
+		if rekeyCheckFlag {
+			if pending > 0 {
+				return errors.Errorf("%d of %d manifests still use %s", pending, len(filenames), rekeyFromFlag)
+			}
+			pterm.Success.Printf("No manifests use %s\n", rekeyFromFlag)
+		} else {
+			pterm.Info.Printf("Re-encrypted %d of %d manifests\n", changed, len(filenames))
+		}
+		if failed > 0 {
+			return errors.Errorf("%d manifests failed", failed)
+		}
+		return nil
+	},
+}
+
+// findManifestFiles lists the YAML files under dir, skipping hidden files
+// and directories such as .keys.yml and .git.
+func findManifestFiles(dir string) ([]string, error) {
+	filenames := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && (filepath.Ext(path) == ".yml" || filepath.Ext(path) == ".yaml") {
+			filenames = append(filenames, path)
+		}
+		return nil
+	})
+	return filenames, err
+}
+
+// loadRekeyManifest decrypts a manifest and checks if any secret in it has
+// values encrypted with the --from key.
+func loadRekeyManifest(cmd *cobra.Command, filename string, keyProvider edit.KeyProvider, aliases map[string][]string) (edit.Manifest, bool, error) {
+	inFile, err := os.Open(filename)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error reading file")
+	}
+	defer func() { _ = inFile.Close() }()
+	manifest, err := edit.NewManifest(inFile)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error decoding YAML")
+	}
+	err = checkStrict(cmd, filename, manifest)
+	if err != nil {
+		return nil, false, err
+	}
+	err = manifest.Decrypt(keyProvider, true)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, obj := range manifest {
+		for _, keyId := range obj.KeyIds {
+			if isKey(keyProvider, keyId, rekeyFromFlag, aliases) {
+				return manifest, true, nil
+			}
+		}
+	}
+	return manifest, false, nil
+}
+
+// rekeyManifest re-encrypts the secrets using the --from key with the --to
+// key and writes the manifest back.
+func rekeyManifest(filename string, manifest edit.Manifest, keyProvider edit.KeyProvider, aliases map[string][]string) error {
+	err := checkManifestKeys(keyProvider, filename, manifest, rekeyToFlag, true)
+	if err != nil {
+		return err
+	}
+	for _, obj := range manifest {
+		// Only EncryptedSecrets are re-encrypted, DecryptedSecrets are kept.
+		if obj.Kind == "" || obj.OrigEnc == nil {
+			continue
+		}
+		obj.AfterDec = obj.OrigDec
+		usesOld := false
+		for _, keyId := range obj.KeyIds {
+			usesOld = usesOld || isKey(keyProvider, keyId, rekeyFromFlag, aliases)
+		}
+		if usesOld {
+			err = obj.Encrypt(keyProvider, rekeyToFlag, true, true)
+		} else {
+			err = obj.Encrypt(keyProvider, obj.KeyId, false, false)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "error encrypting %s/%s", obj.Meta.GetNamespace(), obj.Meta.GetName())
+		}
+	}
+	return writeManifest(filename, manifest)
+}
+
+// isKey checks if a key ID returned by the key provider is the wanted key,
+// which may be given as a key ID, key ARN or alias. Aliases are cached by key ID.
+func isKey(keyProvider edit.KeyProvider, keyId string, want string, aliases map[string][]string) bool {
+	if keyId == want || strings.HasSuffix(keyId, "/"+want) {
+		return true
+	}
+	keyAliases, ok := aliases[keyId]
+	if !ok {
+		keyAliases, _ = keyProvider.ListAliases(keyId)
+		aliases[keyId] = keyAliases
+	}
+	return slices.Contains(keyAliases, want)
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Ridecell/ridectl/pkg/cmd/edit"
+)
+
+// Two throwaway 32 byte keys.
+const testKeys = `alias/sandbox: MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=
+alias/other: ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=
+`
+
+func newTestKeyProvider(t *testing.T) edit.KeyProvider {
+	t.Helper()
+	keysPath := filepath.Join(t.TempDir(), "keys.yml")
+	err := os.WriteFile(keysPath, []byte(testKeys), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyProvider, err := edit.NewLocalKeyProvider(keysPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyProvider
+}
+
+func decryptTestManifest(t *testing.T, keyProvider edit.KeyProvider, text string) edit.Manifest {
+	t.Helper()
+	manifest, err := edit.NewManifest(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = manifest.Decrypt(keyProvider, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+func TestRekeyMixedManifest(t *testing.T) {
+	keyProvider := newTestKeyProvider(t)
+	encrypted, err := edit.NewManifest(strings.NewReader("apiVersion: secrets.controllers.ridecell.io/v1beta2\nkind: DecryptedSecret\nmetadata:\n  name: encrypted\n  namespace: summon-test-dev\ndata:\n  KEY: value\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = encrypted.Encrypt(keyProvider, "alias/sandbox", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	err = encrypted.Serialize(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted := "apiVersion: secrets.controllers.ridecell.io/v1beta2\nkind: DecryptedSecret\nmetadata:\n  name: decrypted\n  namespace: summon-test-dev\ndata:\n  PLAIN: text\n"
+	filename := filepath.Join(t.TempDir(), "test.yml")
+	err = os.WriteFile(filename, []byte(buf.String()+"---\n"+decrypted), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rekeyFromFlag, rekeyToFlag = "alias/sandbox", "alias/other"
+	t.Cleanup(func() { rekeyFromFlag, rekeyToFlag = "", "" })
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rekeyManifest(filename, decryptTestManifest(t, keyProvider, string(text)), keyProvider, map[string][]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err = os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(text), "---\n"+decrypted) {
+		t.Errorf("DecryptedSecret was changed:\n%s", text)
+	}
+	manifest := decryptTestManifest(t, keyProvider, string(text))
+	obj, err := manifest.FindSecret("encrypted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.KeyId != "alias/other" {
+		t.Errorf("re-encrypted with %s, want alias/other", obj.KeyId)
+	}
+	if !reflect.DeepEqual(obj.Data, map[string]string{"KEY": "value"}) {
+		t.Errorf("re-encrypted data is %v", obj.Data)
+	}
+}
+
+func TestRekeyDeniedKey(t *testing.T) {
+	keyProvider := newTestKeyProvider(t)
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, ".keys.yml"), []byte("version: 2\nrules:\n  - match: '*'\n    deny_keys: [\"alias/other\"]\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := edit.NewManifest(strings.NewReader("apiVersion: secrets.controllers.ridecell.io/v1beta2\nkind: DecryptedSecret\nmetadata:\n  name: test\n  namespace: summon-test-dev\ndata:\n  KEY: value\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = manifest.Encrypt(keyProvider, "alias/sandbox", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	err = manifest.Serialize(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "test.yml")
+	err = os.WriteFile(filename, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rekeyFromFlag, rekeyToFlag = "alias/sandbox", "alias/other"
+	t.Cleanup(func() { rekeyFromFlag, rekeyToFlag = "", "" })
+	err = rekeyManifest(filename, decryptTestManifest(t, keyProvider, buf.String()), keyProvider, map[string][]string{})
+	if err == nil || !strings.Contains(err.Error(), "denies alias/other") {
+		t.Errorf("got error %v, want the key to be denied", err)
+	}
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != buf.String() {
+		t.Errorf("manifest was rewritten:\n%s", text)
+	}
+}
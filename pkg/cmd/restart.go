@@ -24,8 +24,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Ridecell/ridectl/pkg/audit"
 	"github.com/Ridecell/ridectl/pkg/kubernetes"
-	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
@@ -45,6 +45,18 @@ func init() {
 	rootCmd.AddCommand(rollingRestartCmd)
 }
 
+var restartInstanceFlag string
+var restartComponentFlag string
+var restartPgdumpNameFlag string
+var restartPgdumpNamespaceFlag string
+
+func init() {
+	rollingRestartCmd.Flags().StringVar(&restartInstanceFlag, "instance", "", "(optional) SummonPlatform/Microservice name to restart")
+	rollingRestartCmd.Flags().StringVar(&restartComponentFlag, "component", "", "(optional) component type of the pods to restart")
+	rollingRestartCmd.Flags().StringVar(&restartPgdumpNameFlag, "name", "", "(optional) Postgresdump object name to restart")
+	rollingRestartCmd.Flags().StringVar(&restartPgdumpNamespaceFlag, "namespace", "", "(optional) Postgresdump object namespace")
+}
+
 /*
 We are using summon-operator to create deployments of summon-platform. So when we try to do rollout restart,
 it does not behave as expected because summon-operator is constantly watching the deployments and reconciles if anything changes.
@@ -53,7 +65,7 @@ This becomes very tricky to handle in operator-itself. Hence we have implemented
 Ref: https://ridecell.atlassian.net/browse/DEVOPS-2925
 */
 var rollingRestartCmd = &cobra.Command{
-	Use:   "restart",
+	Use:   "restart [migration|pods|postgresdump]",
 	Short: "Performs restart of Pods, migration job or Postgresdump job.",
 	Long: "Restarts pods or jobs depending on user's selection.\n\n" +
 		"Specify instance name / microservice name in following format:\n" +
@@ -61,8 +73,13 @@ var rollingRestartCmd = &cobra.Command{
 		"Microservices    :   svc-<region>-<env>-<microservice>   -- e.g. svc-us-master-webhook-sms\n\n" +
 		"For restarting pods, provide component name. For example:\n" +
 		"  Summon components: web, celeryd, static, celeryredbeat, kafkaconsumer, daphne, channelworker, platform-one, etc\n" +
-		"  Microservice components: web, celery-beat, celery-worker, kafka-consumer, etc",
+		"  Microservice components: web, celery-beat, celery-worker, kafka-consumer, etc\n\n" +
+		"Anything not given as an argument or flag is prompted for, e.g.\n" +
+		"  ridectl restart pods --instance darwin-qa --component web",
 	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return fmt.Errorf("too many arguments")
+		}
 		return nil
 	},
 	PreRunE: func(cmd *cobra.Command, args []string) error {
@@ -93,25 +110,20 @@ var rollingRestartCmd = &cobra.Command{
 
 		restartTypes := []string{"Migration", "Pods", "PostgresDump Job"}
 
-		restartPrompt := promptui.Select{
-			Label: "Select what to restart:",
-			Items: restartTypes,
+		typeArg := ""
+		if len(args) > 0 {
+			typeArg = args[0]
 		}
-
-		_, restartType, err := restartPrompt.Run()
+		restartType, err := promptSelect("Select what to restart:", restartTypes, "restart type", typeArg)
 		if err != nil {
-			return errors.Wrapf(err, "Prompt failed")
+			return err
 		}
 
 		switch restartType {
 		case "Migration":
-			prompt := promptui.Prompt{
-				Label:    "Enter SummonPlatform instance name (e.g. darwin-qa)",
-				Validate: validateInstance,
-			}
-			instanceName, err := prompt.Run()
+			instanceName, err := promptInput("Enter SummonPlatform instance name (e.g. darwin-qa)", validateInstance, "--instance", restartInstanceFlag)
 			if err != nil {
-				return errors.Wrapf(err, "Prompt failed")
+				return err
 			}
 
 			target, kubeObj, exist := utils.DoesInstanceExist(instanceName, inCluster, kubeconfigFlag)
@@ -131,25 +143,18 @@ var rollingRestartCmd = &cobra.Command{
 				return errors.Wrap(err, "failed to restart job")
 			}
 			pterm.Success.Printf("Restarted migrations for %s\n", target.Name)
+			auditLog(audit.Record{Command: "restart", Instance: instanceName, Namespace: target.Namespace, Context: kubeObj.Context, Details: map[string]string{"type": restartType}}, kubeObj.Client)
 
 		case "Pods":
 			pterm.Warning.Println("Warning: This might cause downtime for your services.")
 
-			prompt := promptui.Prompt{
-				Label:    "Enter SummonPlatform/Microservice name (e.g. darwin-qa or svc-us-master-webhook-sms)",
-				Validate: validateInstance,
-			}
-			instanceName, err := prompt.Run()
+			instanceName, err := promptInput("Enter SummonPlatform/Microservice name (e.g. darwin-qa or svc-us-master-webhook-sms)", validateInstance, "--instance", restartInstanceFlag)
 			if err != nil {
-				return errors.Wrapf(err, "Prompt failed")
-			}
-			prompt = promptui.Prompt{
-				Label:    "Enter component type (e.g. web, celeryd/celery-worker, static, celeryredbeat/celery-beat, kafkaconsumer/kafka-consumer, etc)",
-				Validate: validateInput,
+				return err
 			}
-			component, err := prompt.Run()
+			component, err := promptInput("Enter component type (e.g. web, celeryd/celery-worker, static, celeryredbeat/celery-beat, kafkaconsumer/kafka-consumer, etc)", validateInput, "--component", restartComponentFlag)
 			if err != nil {
-				return errors.Wrapf(err, "Prompt failed")
+				return err
 			}
 
 			target, kubeObj, exist := utils.DoesInstanceExist(instanceName, inCluster, kubeconfigFlag)
@@ -158,29 +163,19 @@ var rollingRestartCmd = &cobra.Command{
 			}
 
 			var deploymentName string
-			podLabels := make(map[string]string)
-
 			switch target.Type {
 			case "summon":
-				podLabels["app.kubernetes.io/instance"] = fmt.Sprintf("%s-%s", target.Name, component)
 				deploymentName = fmt.Sprintf("%s-%s", target.Name, component)
 			case "microservice":
-				podLabels["app"] = fmt.Sprintf("%s-svc-%s", target.Env, target.Namespace)
-				podLabels["environment"] = target.Env
-				podLabels["region"] = target.Region
-				podLabels["role"] = component
 				deploymentName = fmt.Sprintf("%s-svc-%s-%s", target.Env, target.Namespace, component)
 			}
 
 			pterm.Info.Printf("Restarting pods for %s : %s\n", target.Name, component)
+			auditLog(audit.Record{Command: "restart", Instance: instanceName, Namespace: target.Namespace, Context: kubeObj.Context, Details: map[string]string{"type": restartType, "component": component}}, kubeObj.Client)
 
-			labelSet := labels.Set{}
-			for k, v := range podLabels {
-				labelSet[k] = v
-			}
 			listOptions := &client.ListOptions{
 				Namespace:     target.Namespace,
-				LabelSelector: labels.SelectorFromSet(labelSet),
+				LabelSelector: labels.SelectorFromSet(kubernetes.PodLabels(target, component)),
 			}
 			pods := &corev1.PodList{}
 			err = kubeObj.Client.List(context.TODO(), pods, listOptions)
@@ -217,21 +212,13 @@ var rollingRestartCmd = &cobra.Command{
 			pterm.Success.Printf("Successfully restarted pods for %s : %s\n", target.Name, component)
 
 		case "PostgresDump Job":
-			prompt := promptui.Prompt{
-				Label:    "Enter Postgresdump object name",
-				Validate: validateInput,
-			}
-			pgdumpName, err := prompt.Run()
+			pgdumpName, err := promptInput("Enter Postgresdump object name", validateInput, "--name", restartPgdumpNameFlag)
 			if err != nil {
-				return errors.Wrapf(err, "Prompt failed")
-			}
-			prompt = promptui.Prompt{
-				Label:    "Enter Postgresdump object namespace",
-				Validate: validateInput,
+				return err
 			}
-			pgdumpNamespace, err := prompt.Run()
+			pgdumpNamespace, err := promptInput("Enter Postgresdump object namespace", validateInput, "--namespace", restartPgdumpNamespaceFlag)
 			if err != nil {
-				return errors.Wrapf(err, "Prompt failed")
+				return err
 			}
 
 			// Create Subject object for PostgresDump Job object
@@ -259,6 +246,7 @@ var rollingRestartCmd = &cobra.Command{
 				return errors.Wrap(err, "failed to restart job")
 			}
 			pterm.Success.Printf("Restarted PostgresDump job for %s\n", pgdumpName)
+			auditLog(audit.Record{Command: "restart", Instance: pgdumpName, Namespace: pgdumpNamespace, Context: kubeObj.Context, Details: map[string]string{"type": restartType}}, kubeObj.Client)
 		}
 		return nil
 	},
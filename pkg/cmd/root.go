@@ -27,11 +27,14 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
+	"github.com/Ridecell/ridectl/pkg/kubernetes"
 	"github.com/Ridecell/ridectl/pkg/utils"
 	"github.com/inconshreveable/go-update"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"k8s.io/client-go/kubernetes/scheme"
 
 	dbv1beta2 "github.com/Ridecell/ridecell-controllers/apis/db/v1beta2"
@@ -72,9 +75,22 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&kubeconfigFlag, "kubeconfig", "", "(optional) absolute path to the kubeconfig file")
 	rootCmd.Flags().BoolVar(&versionFlag, "version", false, "--version")
 	rootCmd.PersistentFlags().BoolVar(&inCluster, "incluster", false, "(optional) use in cluster kube config")
-
-	// Display announcement banner if present
-	displayAnnouncementBanner()
+	rootCmd.PersistentFlags().StringVar(&kubernetes.PinnedContext, "context", "", "(optional) kubeconfig context to use instead of searching all clusters")
+	// --cluster is accepted as another name for --context
+	rootCmd.SetGlobalNormalizationFunc(func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "cluster" {
+			name = "context"
+		}
+		return pflag.NormalizedName(name)
+	})
+	rootCmd.PersistentPreRunE = func(_ *cobra.Command, _ []string) error {
+		// Prompt for the context of instances found in more than one,
+		// otherwise that is an error.
+		if !nonInteractiveFlag {
+			kubernetes.SelectContext = selectContext
+		}
+		return checkOutputFormat()
+	}
 
 	// Check if ridectl is running on Github actions runner
 	if os.Getenv("GITHUB_ACTIONS") == "true" {
@@ -83,19 +99,6 @@ func init() {
 		_ = os.Setenv("RIDECTL_TSH_CHECK", "false")
 	}
 
-	// check version and update if not latest
-	if !isLatestVersion() {
-		skipUpgrade := os.Getenv("RIDECTL_SKIP_UPGRADE")
-		if skipUpgrade != "true" {
-			pterm.Info.Println("Upgrading ridectl.")
-			selfUpdate()
-			pterm.Info.Println("Ridectl update is completed. Please re-run the command.")
-			os.Exit(0)
-		} else {
-			pterm.Info.Println("RIDECTL_SKIP_UPGRADE is set to true, skipping ridectl upgrade.")
-		}
-	}
-
 	// Register all types from summon-operator and ridecell-controllers secrets
 	_ = summonv1beta2.AddToScheme(scheme.Scheme)
 	_ = secretsv1beta2.AddToScheme(scheme.Scheme)
@@ -111,9 +114,44 @@ func init() {
 	ridectlHomeDir = userHomeDir + "/.ridectl"
 	utils.CreateDirIfNotPresent(ridectlHomeDir)
 	ridectlConfigFile = ridectlHomeDir + "/ridectl.cfg"
+
+	// Which clusters are searched for instances
+	kubernetes.Clusters = kubernetes.LoadClusterConfig(ridectlConfigFile)
+
+	// Cache the cluster context instances are found in
+	kubernetes.ContextCacheFile = ridectlHomeDir + "/contexts.json"
+	if ttl := os.Getenv("RIDECTL_CONTEXT_CACHE_TTL"); ttl != "" {
+		kubernetes.ContextCacheTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			pterm.Warning.Printf("invalid RIDECTL_CONTEXT_CACHE_TTL %s, not caching contexts: %v\n", ttl, err)
+			kubernetes.ContextCacheTTL = 0
+		}
+	}
+}
+
+// startupChecks shows the announcement banner and upgrades ridectl if it is
+// not the latest version. Execute runs it before the command, tests can
+// replace it.
+var startupChecks = func() {
+	// Display announcement banner if present
+	displayAnnouncementBanner()
+
+	// check version and update if not latest
+	if !isLatestVersion() {
+		skipUpgrade := os.Getenv("RIDECTL_SKIP_UPGRADE")
+		if skipUpgrade != "true" {
+			pterm.Info.Println("Upgrading ridectl.")
+			selfUpdate()
+			pterm.Info.Println("Ridectl update is completed. Please re-run the command.")
+			os.Exit(0)
+		} else {
+			pterm.Info.Println("RIDECTL_SKIP_UPGRADE is set to true, skipping ridectl upgrade.")
+		}
+	}
 }
 
 func Execute() {
+	startupChecks()
 	if err := rootCmd.Execute(); err != nil {
 		pterm.Error.Println(err)
 		pterm.Error.Println("For FAQs and Troubleshooting: https://docs.google.com/document/d/1v6lbH4NgN6rHBHpELWrcQ4CyqwVeSgeP/preview")
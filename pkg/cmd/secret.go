@@ -0,0 +1,499 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Ridecell/ridectl/pkg/audit"
+	"github.com/Ridecell/ridectl/pkg/cmd/edit"
+	"github.com/Ridecell/ridectl/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretGetCmd)
+	secretCmd.AddCommand(secretSetCmd)
+	secretCmd.AddCommand(secretUnsetCmd)
+	secretCmd.AddCommand(secretDriftCmd)
+}
+
+var secretObjectFlag string
+var secretFromFileFlag string
+var secretStdinFlag bool
+
+func init() {
+	secretCmd.PersistentFlags().StringVarP(&filenameFlag, "file", "f", "", "(optional) Path to the manifest file, instead of an instance name")
+	secretCmd.PersistentFlags().StringVar(&secretObjectFlag, "object", "", "(optional) Name of the EncryptedSecret, required if the manifest has more than one")
+	secretCmd.PersistentFlags().StringVar(&keyProviderFlag, "key-provider", "", keyProviderUsage)
+	secretCmd.PersistentFlags().BoolVar(&strictFlag, "strict", false, strictUsage)
+	secretSetCmd.Flags().StringVarP(&keyIdFlag, "key", "k", "", "(optional) KMS key ID to use for encrypting, re-encrypts every value with it")
+	secretSetCmd.Flags().StringVar(&secretFromFileFlag, "from-file", "", "(optional) Read the value of a single KEY from a file")
+	secretSetCmd.Flags().BoolVar(&secretStdinFlag, "stdin", false, "(optional) Read the value of a single KEY from stdin")
+	secretSetCmd.Flags().StringVar(&envelopeFlag, "envelope", edit.EnvelopeV1, envelopeUsage)
+}
+
+/*
+
+An explanation of the secret process:
+
+1. The manifest file is loaded and parsed.
+2. The chosen EncryptedSecret is decrypted using the key provider.
+3. For get, the value is printed. Otherwise the requested keys are changed in the decrypted data.
+4. The data is encrypted again. Values which did not change keep their existing ciphertext.
+5. The manifest is written back, only touching the changed keys.
+
+For drift, every EncryptedSecret in the manifest, or just --object, is
+decrypted and compared with the Secret of the same name the operator created
+in the instance's cluster. Only hashes of differing values are shown.
+
+*/
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Get or change individual secret values in an instance manifest",
+	Long: "Non-interactive access to single keys of an EncryptedSecret in an instance manifest.\n" +
+		"  ridectl secret get <tenant>-<env> <KEY>\n" +
+		"  ridectl secret set <tenant>-<env> <KEY>=<VALUE>... | <KEY> --from-file <path> | <KEY> --stdin\n" +
+		"  ridectl secret unset <tenant>-<env> <KEY>...\n" +
+		"  ridectl secret drift <tenant>-<env>",
+}
+
+var secretGetCmd = &cobra.Command{
+	Use:   "get [flags] <cluster_name> [KEY]",
+	Short: "Print a decrypted secret value, or list the keys if none is given",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Keep stdout for the value only.
+		pterm.SetDefaultOutput(os.Stderr)
+
+		filename, keys, err := secretArgs(args)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 1 {
+			return errors.New("too many arguments")
+		}
+		_, obj, _, err := loadSecret(cmd, filename)
+		if err != nil {
+			return err
+		}
+
+		if len(keys) == 0 {
+			names := []string{}
+			for key := range obj.OrigDec.Data {
+				names = append(names, key)
+			}
+			sort.Strings(names)
+			for _, key := range names {
+				fmt.Println(key)
+			}
+			return nil
+		}
+
+		value, ok := obj.OrigDec.Data[keys[0]]
+		if !ok {
+			return errors.Errorf("key %s not found in %s", keys[0], obj.Meta.GetName())
+		}
+		fmt.Print(value)
+		if !strings.HasSuffix(value, "\n") {
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set [flags] <cluster_name> <KEY>=<VALUE>...",
+	Short: "Set secret values, encrypting only the changed keys",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filename, keys, err := secretArgs(args)
+		if err != nil {
+			return err
+		}
+		values, err := secretValues(keys)
+		if err != nil {
+			return err
+		}
+		err = edit.SetEnvelope(envelopeFlag)
+		if err != nil {
+			return err
+		}
+		manifest, obj, keyProvider, err := loadSecret(cmd, filename)
+		if err != nil {
+			return err
+		}
+
+		obj.AfterDec = obj.OrigDec.DeepCopy()
+		for key, value := range values {
+			obj.AfterDec.Data[key] = value
+		}
+
+		keyId := keyIdFlag
+		if keyId == "" {
+			keyId, err = edit.FindKeyId(filename)
+			if err != nil {
+				return errors.Wrap(err, "error finding key ID")
+			}
+		}
+		err = checkManifestKeys(keyProvider, filename, edit.Manifest{obj}, keyId, keyIdFlag != "")
+		if err != nil {
+			return err
+		}
+		// Like edit, -k re-encrypts every value so none are left under the
+		// old key, or sealed with its data key.
+		err = obj.Encrypt(keyProvider, keyId, keyIdFlag != "", keyIdFlag != "")
+		if err != nil {
+			return errors.Wrap(err, "error encrypting secret")
+		}
+
+		err = writeManifest(filename, manifest)
+		if err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(values) {
+			pterm.Success.Printf("Set %s in %s/%s\n", key, obj.Meta.GetNamespace(), obj.Meta.GetName())
+		}
+		auditSecret("secret set", args, filename, obj)
+		return nil
+	},
+}
+
+var secretUnsetCmd = &cobra.Command{
+	Use:   "unset [flags] <cluster_name> <KEY>...",
+	Short: "Remove secret values",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filename, keys, err := secretArgs(args)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return errors.New("at least one key is required")
+		}
+		manifest, obj, keyProvider, err := loadSecret(cmd, filename)
+		if err != nil {
+			return err
+		}
+
+		obj.AfterDec = obj.OrigDec.DeepCopy()
+		for _, key := range keys {
+			if _, ok := obj.AfterDec.Data[key]; !ok {
+				return errors.Errorf("key %s not found in %s", key, obj.Meta.GetName())
+			}
+			delete(obj.AfterDec.Data, key)
+		}
+
+		// The remaining keys are unchanged, so this only reuses their existing ciphertext.
+		err = obj.Encrypt(keyProvider, obj.KeyId, false, false)
+		if err != nil {
+			return errors.Wrap(err, "error encrypting secret")
+		}
+
+		err = writeManifest(filename, manifest)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			pterm.Success.Printf("Removed %s from %s/%s\n", key, obj.Meta.GetNamespace(), obj.Meta.GetName())
+		}
+		auditSecret("secret unset", args, filename, obj)
+		return nil
+	},
+}
+
+var secretDriftCmd = &cobra.Command{
+	Use:          "drift [flags] <tenant>-<env>",
+	Short:        "Compare the secrets in a manifest with the ones deployed in the cluster",
+	Long:         "Compare the secrets in a manifest with the ones deployed in the cluster, listing keys missing from the cluster, extra keys in the cluster, and keys whose values differ. Exits non-zero if any secret differs.",
+	SilenceUsage: true,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("instance name argument is required")
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		utils.CheckTshLogin()
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filename, _, err := secretArgs(args)
+		if err != nil {
+			return err
+		}
+		inFile, err := os.Open(filename)
+		if err != nil {
+			return errors.Wrapf(err, "error reading input file %s", filename)
+		}
+		defer func() { _ = inFile.Close() }()
+		manifest, err := edit.NewManifest(inFile)
+		if err != nil {
+			return errors.Wrap(err, "error decoding input YAML")
+		}
+		secrets := edit.Manifest{}
+		if secretObjectFlag != "" {
+			obj, err := manifest.FindSecret(secretObjectFlag)
+			if err != nil {
+				return err
+			}
+			secrets = append(secrets, obj)
+		} else {
+			for _, obj := range manifest {
+				if obj.Kind == "EncryptedSecret" {
+					secrets = append(secrets, obj)
+				}
+			}
+		}
+		if len(secrets) == 0 {
+			return errors.Errorf("no EncryptedSecrets found in %s", filename)
+		}
+		err = checkStrict(cmd, filename, secrets)
+		if err != nil {
+			return err
+		}
+
+		keyProvider, err := getKeyProvider(filename)
+		if err != nil {
+			return err
+		}
+		err = secrets.Decrypt(keyProvider, false)
+		if err != nil {
+			return err
+		}
+
+		target, kubeObj, exist := utils.DoesInstanceExist(args[0], inCluster, kubeconfigFlag)
+		if !exist {
+			os.Exit(1)
+		}
+
+		drifted := 0
+		for _, obj := range secrets {
+			namespace := obj.Meta.GetNamespace()
+			if namespace == "" {
+				namespace = target.Namespace
+			}
+			secret := &corev1.Secret{}
+			err = kubeObj.Client.Get(context.Background(), types.NamespacedName{Name: obj.Meta.GetName(), Namespace: namespace}, secret)
+			if err != nil {
+				if k8serrors.IsNotFound(err) {
+					pterm.Warning.Printf("%s/%s: not found in %s\n", namespace, obj.Meta.GetName(), kubeObj.Context)
+					drifted++
+					continue
+				}
+				return errors.Wrapf(err, "error getting secret %s/%s", namespace, obj.Meta.GetName())
+			}
+
+			clusterData := map[string]string{}
+			for key, value := range secret.Data {
+				clusterData[key] = string(value)
+			}
+			diffs := edit.DiffData(clusterData, obj.OrigDec.Data)
+			if len(diffs) == 0 {
+				pterm.Success.Printf("%s/%s: matches the cluster\n", namespace, obj.Meta.GetName())
+				continue
+			}
+			drifted++
+			pterm.Println(pterm.Bold.Sprintf("%s/%s:", namespace, obj.Meta.GetName()))
+			for _, diff := range diffs {
+				switch diff.Action {
+				case edit.KeyAdded:
+					pterm.Println(pterm.FgGreen.Sprintf("  + %s: missing from the cluster (local %s)", diff.Key, valueHash(diff.New)))
+				case edit.KeyRemoved:
+					pterm.Println(pterm.FgRed.Sprintf("  - %s: only in the cluster (cluster %s)", diff.Key, valueHash(diff.Old)))
+				case edit.KeyChanged:
+					pterm.Println(pterm.FgYellow.Sprintf("  ~ %s: differs (local %s, cluster %s)", diff.Key, valueHash(diff.New), valueHash(diff.Old)))
+				}
+			}
+		}
+
+		if drifted > 0 {
+			return errors.Errorf("%d of %d secrets differ from %s", drifted, len(secrets), kubeObj.Context)
+		}
+		return nil
+	},
+}
+
+// valueHash returns a short hash of a secret value, to compare values without
+// showing them.
+func valueHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// auditSecret records the keys a secret command changed in the audit log.
+func auditSecret(command string, args []string, filename string, obj *edit.Object) {
+	instance := ""
+	if filenameFlag == "" {
+		instance = args[0]
+	}
+	diff := edit.ObjectDiff{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName(), Keys: edit.DiffData(obj.OrigDec.Data, obj.AfterDec.Data)}
+	auditLog(audit.Record{
+		Command:  command,
+		Instance: instance,
+		Keys:     auditKeys([]edit.ObjectDiff{diff}),
+		Details:  map[string]string{"file": filename},
+	}, nil)
+}
+
+// secretArgs works out the manifest file from either --file or the instance
+// name argument, returning it along with the remaining arguments.
+func secretArgs(args []string) (string, []string, error) {
+	if filenameFlag != "" {
+		return filenameFlag, args, nil
+	}
+	if len(args) == 0 {
+		return "", nil, errors.New("cluster name argument is required")
+	}
+	tenant, env, err := parseInstanceName(args[0])
+	if err != nil {
+		return "", nil, err
+	}
+	filename, err := findManifestFile(tenant, env)
+	if err != nil {
+		return "", nil, err
+	}
+	if filename == "" {
+		return "", nil, errors.Errorf("no manifest found for %s, use ridectl edit to create one", args[0])
+	}
+	return filename, args[1:], nil
+}
+
+// secretValues parses KEY=VALUE arguments, or a single KEY whose value comes
+// from --from-file or --stdin.
+func secretValues(args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, errors.New("at least one KEY=VALUE is required")
+	}
+	values := map[string]string{}
+	if secretFromFileFlag != "" || secretStdinFlag {
+		if len(args) != 1 || strings.Contains(args[0], "=") {
+			return nil, errors.New("a single KEY is required with --from-file or --stdin")
+		}
+		var value []byte
+		var err error
+		if secretStdinFlag {
+			value, err = io.ReadAll(os.Stdin)
+		} else {
+			value, err = os.ReadFile(secretFromFileFlag)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading value")
+		}
+		values[args[0]] = string(value)
+		return values, nil
+	}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, errors.Errorf("expected KEY=VALUE, got %s", arg)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// loadSecret parses a manifest and decrypts the EncryptedSecret picked by --object.
+func loadSecret(cmd *cobra.Command, filename string) (edit.Manifest, *edit.Object, edit.KeyProvider, error) {
+	inFile, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "error reading input file %s", filename)
+	}
+	defer func() { _ = inFile.Close() }()
+	manifest, err := edit.NewManifest(inFile)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "error decoding input YAML")
+	}
+	obj, err := manifest.FindSecret(secretObjectFlag)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if obj.Kind != "EncryptedSecret" {
+		return nil, nil, nil, errors.Errorf("%s is a %s, not an EncryptedSecret", obj.Meta.GetName(), obj.Kind)
+	}
+	err = checkStrict(cmd, filename, edit.Manifest{obj})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	keyProvider, err := getKeyProvider(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	err = obj.Decrypt(keyProvider, false)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "error decrypting %s/%s", obj.Meta.GetNamespace(), obj.Meta.GetName())
+	}
+	return manifest, obj, keyProvider, nil
+}
+
+// writeManifest serializes the manifest before replacing the file, so a
+// failure part way through does not leave it truncated.
+func writeManifest(filename string, manifest edit.Manifest) error {
+	buf := &bytes.Buffer{}
+	err := manifest.Serialize(buf)
+	if err != nil {
+		return errors.Wrap(err, "error encoding manifest")
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmpfile, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "error writing %s", filename)
+	}
+	defer func() { _ = os.Remove(tmpfile.Name()) }()
+	_, err = tmpfile.Write(buf.Bytes())
+	if err == nil {
+		err = tmpfile.Chmod(mode)
+	}
+	if closeErr := tmpfile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpfile.Name(), filename)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error writing %s", filename)
+	}
+	return nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
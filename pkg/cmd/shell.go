@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Ridecell/ridectl/pkg/audit"
 	"github.com/Ridecell/ridectl/pkg/exec"
 	"github.com/Ridecell/ridectl/pkg/kubernetes"
 	"github.com/pterm/pterm"
@@ -63,25 +64,9 @@ var shellCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		podLabels := make(map[string]string)
-		switch target.Type {
-		case "summon":
-			podLabels["app.kubernetes.io/instance"] = fmt.Sprintf("%s-web", args[0])
-		case "microservice":
-			podLabels["app"] = fmt.Sprintf("%s-svc-%s", target.Env, target.Namespace)
-			podLabels["environment"] = target.Env
-			podLabels["region"] = target.Region
-			podLabels["role"] = "web"
-		}
-
-		labelSet := labels.Set{}
-		for k, v := range podLabels {
-			labelSet[k] = v
-		}
-
 		listOptions := &client.ListOptions{
 			Namespace:     target.Namespace,
-			LabelSelector: labels.SelectorFromSet(labelSet),
+			LabelSelector: labels.SelectorFromSet(kubernetes.PodLabels(target, "web")),
 		}
 
 		podList := &corev1.PodList{}
@@ -108,6 +93,8 @@ var shellCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		auditLog(audit.Record{Command: "shell", Instance: args[0], Namespace: target.Namespace, Context: kubeObj.Context, Details: map[string]string{"pod": pod.Name}}, kubeObj.Client)
+
 		// Spawn kubectl exec.
 		pterm.Info.Printf("Connecting to %s/%s\n", pod.Namespace, pod.Name)
 
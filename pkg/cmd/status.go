@@ -17,15 +17,14 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
-	osExec "os/exec"
 	"strings"
 	"time"
 
 	"github.com/Ridecell/ridectl/pkg/utils"
-	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
-	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
@@ -34,77 +33,46 @@ func init() {
 }
 
 var follow bool
+var statusTypeFlag string
+var statusUntilFlag string
+var statusTimeoutFlag time.Duration
 
 func init() {
 	statusCmd.Flags().BoolVarP(&follow, "follow", "f", false, "(optional) follows the status of tenant until terminated")
-}
-
-// Helper functions for running kubectl commands to retrieve object info.
-func getData(objType string, context string, namespace string, tenant string) (string, error) {
-	var data []byte
-	var err error
-
-	switch objType {
-	case "summon":
-		summonData, err := TempFS.ReadFile("templates/show_summon.tpl")
-		if err != nil {
-			return "", errors.Wrap(err, "error reading show_summon.tpl")
-		}
-
-		data, err = osExec.Command("kubectl", "get", "summonplatform.app.summon.ridecell.io", "-n", namespace, "--context", context, tenant, "-o", "go-template="+string(summonData)).Output()
-		if err != nil {
-			return "", errors.Wrap(err, "error getting summon platform info")
-		}
-	case "deployment":
-		deploymentData, err := TempFS.ReadFile("templates/show_deployments.tpl")
-		if err != nil {
-			return "", errors.Wrap(err, "error reading show_deployments.tpl")
-		}
-
-		data, err = osExec.Command("kubectl", "get", "deployment", "-n", namespace, "--context", context, "-l", "app.kubernetes.io/part-of="+tenant, "-o", "go-template="+string(deploymentData)).Output()
-		if err != nil {
-			return "", errors.Wrap(err, "error getting deployment info")
-		}
-	default:
-		objectData, err := TempFS.ReadFile("templates/show_postgresdump.tpl")
-		if err != nil {
-			return "", errors.Wrap(err, "error reading show_postgresdump.tpl")
-		}
-		command := "kubectl get postgresdumps.db.controllers.ridecell.io -n " + namespace + " --context " + context + " -o" + " go-template='" + string(objectData) + "'"
-		if strings.Contains(tenant, "svc-") {
-			command = "kubectl get postgresdumps.db.controllers.ridecell.io -n " + namespace + " --context " + context + " -o" + " go-template='" + string(objectData) + "'" + " | grep " + tenant
-		}
-		data, err = osExec.Command("bash", "-c", command).Output()
-		if err != nil {
-			return "", errors.Wrap(err, "error getting postgresdump instance info")
-		}
-	}
-
-	return string(data), err
+	statusCmd.Flags().StringVar(&statusTypeFlag, "type", "", "(optional) status to show: summon-platform or db-backup")
+	statusCmd.Flags().StringVar(&statusUntilFlag, "until", "", "(optional) follow until the status is reached, e.g. Ready, or Completed for the newest backup")
+	statusCmd.Flags().DurationVar(&statusTimeoutFlag, "timeout", 0, "(optional) stop following after this long, failing if --until was not reached, e.g. 30m")
 }
 
 var statusCmd = &cobra.Command{
-	Use:   "status",
+	Use:   "status [flags] [<tenant>-<env>]",
 	Short: "Get status report of an Summon Instance",
-	Long:  "Shows status details for all components of a Summon Instance",
+	Long: "Shows status details for all components of a Summon Instance\n" +
+		"The status type and tenant are prompted for when not given, e.g. ridectl status darwin-qa --type db-backup\n" +
+		"--follow watches the status live. To block until it is reached, e.g. in a deploy pipeline:\n" +
+		"  ridectl status darwin-qa --type summon --until Ready --timeout 30m",
+	SilenceUsage: true,
 	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return fmt.Errorf("too many arguments")
+		}
 		return nil
 	},
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		utils.CheckTshLogin()
-		utils.CheckKubectl()
 		return nil
 	},
-	RunE: func(cmd *cobra.Command, args []string) error {
-		followStatus, _ := cmd.Flags().GetBool("follow")
-		statusTypes := []string{"Summon Platform", "DB Backup"}
-		statusPrompt := promptui.Select{
-			Label: "Select ",
-			Items: statusTypes,
+	RunE: func(_ *cobra.Command, args []string) error {
+		if statusUntilFlag != "" || statusTimeoutFlag > 0 {
+			follow = true
 		}
-		_, statusType, err := statusPrompt.Run()
+		if follow && structuredOutput() && statusUntilFlag == "" {
+			return errors.Errorf("--follow needs --until with -o %s", outputFlag)
+		}
+		statusTypes := []string{"Summon Platform", "DB Backup"}
+		statusType, err := promptSelect("Select ", statusTypes, "--type", statusTypeFlag)
 		if err != nil {
-			return errors.Wrapf(err, "Prompt failed")
+			return err
 		}
 		validator := func(input string) error {
 			if input == "" {
@@ -115,79 +83,27 @@ var statusCmd = &cobra.Command{
 			}
 			return nil
 		}
-		instanceNamePromt := promptui.Prompt{
-			Label:    "Enter summon tenant (sandbox-dev) name",
-			Validate: validator,
+		nameArg := ""
+		if len(args) > 0 {
+			nameArg = args[0]
 		}
-		name, err := instanceNamePromt.Run()
+		name, err := promptInput("Enter summon tenant (sandbox-dev) name", validator, "tenant argument", nameArg)
 		if err != nil {
-			return errors.Wrapf(err, "Prompt failed")
+			return err
 		}
 		target, kubeObj, exist := utils.DoesInstanceExist(name, inCluster, kubeconfigFlag)
 		if !exist {
 			os.Exit(1)
 		}
 
-		var sData, dData, pData string
-		if statusType == "Summon Platform" {
-			sData, err = getData("summon", kubeObj.Context, target.Namespace, name)
-			if err != nil {
-				return err
-			}
-			dData, err = getData("deployment", kubeObj.Context, target.Namespace, name)
-			if err != nil {
-				return err
-			}
-		} else {
-			pData, err = getData("postgresdump", kubeObj.Context, target.Namespace, name)
-			if err != nil {
-				return err
-			}
+		if follow {
+			return followStatus(kubeObj, target.Namespace, name, statusType)
 		}
-		if followStatus {
-			area, _ := pterm.DefaultArea.WithRemoveWhenDone().Start()
-			for {
-				p := pterm.DefaultProgressbar
-				p.ShowElapsedTime = false
-				p.RemoveWhenDone = true
-				if statusType == "Summon Platform" {
-					p = *p.WithTotal(2)
-					area.Update(sData, "\n", dData)
-					_, _ = p.Start()
-					p.Title = "Fetching data"
-					sData, err = getData("summon", kubeObj.Context, target.Namespace, name)
-					if err != nil {
-						return err
-					}
-					p.Increment()
 
-					dData, err = getData("deployment", kubeObj.Context, target.Namespace, name)
-					if err != nil {
-						return err
-					}
-					p.Increment()
-				} else {
-					p = *p.WithTotal(1)
-					area.Update(pData)
-					_, _ = p.Start()
-					p.Title = "Fetching data"
-					pData, err = getData("posgtresdump", kubeObj.Context, target.Namespace, name)
-					if err != nil {
-						return err
-					}
-					p.Increment()
-					time.Sleep(time.Second * 10)
-				}
-				_, _ = p.Stop()
-				_ = area.Stop()
-			}
-		} else {
-			if statusType == "Summon Platform" {
-				pterm.Success.Printf("%s\n%s", sData, dData)
-			} else {
-				pterm.Success.Printf("%s", pData)
-			}
+		result, err := getStatusResult(context.Background(), kubeObj, target.Namespace, name, statusType)
+		if err != nil {
+			return err
 		}
-		return nil
+		return printOutput(result)
 	},
 }
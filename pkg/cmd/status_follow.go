@@ -0,0 +1,258 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Ridecell/ridectl/pkg/kubernetes"
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1beta2 "github.com/Ridecell/ridecell-controllers/apis/db/v1beta2"
+	summonv1beta2 "github.com/Ridecell/summon-operator/apis/app/v1beta2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+/*
+
+An explanation of status --follow:
+
+1. Every kind of object the status is made from is watched in the tenant's
+   namespace: the SummonPlatform, Deployments and Pods, or the PostgresDumps.
+   Watches the server ends are started again.
+2. Any watch event, or a resync every minute in case one was missed, reads
+   the status again. The view is only redrawn when the status changed. A
+   failed read is warned about and tried again on the next one.
+3. Changed fields are highlighted until the next change, and the last
+   transitions, such as Deploying → Ready, are listed below the status.
+4. With --until, following stops with success once the status is reached.
+   With --timeout, it fails if that takes too long.
+
+*/
+
+// Number of transitions listed below the status.
+const maxTransitions = 10
+
+// followStatus shows the status of a tenant live until --until is reached,
+// --timeout elapses or it is interrupted.
+func followStatus(kubeObj kubernetes.Kubeobject, namespace string, tenant string, statusType string) error {
+	watchClient, ok := kubeObj.Client.(client.WithWatch)
+	if !ok {
+		return errors.New("cluster client can't watch objects")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var timeout <-chan time.Time
+	if statusTimeoutFlag > 0 {
+		timeout = time.After(statusTimeoutFlag)
+	}
+	changed := make(chan struct{}, 1)
+	errs := make(chan error, 1)
+	for _, list := range statusWatchLists(statusType) {
+		go watchObjects(ctx, watchClient, list, namespace, changed, errs)
+	}
+	resync := time.NewTicker(time.Minute)
+	defer resync.Stop()
+
+	// Only the final status is printed for -o json, yaml or name.
+	var area *pterm.AreaPrinter
+	if !structuredOutput() {
+		area, _ = pterm.DefaultArea.Start()
+		defer func() { _ = area.Stop() }()
+	}
+
+	var last *statusResult
+	transitions := []string{}
+	for {
+		result, err := getStatusResult(ctx, kubeObj, namespace, tenant, statusType)
+		if err != nil {
+			// The API can fail for a moment, or the SummonPlatform be missing
+			// while it is recreated, so wait for the next change.
+			pterm.Warning.Printf("error reading the status of %s, waiting for the next change: %v\n", tenant, err)
+		} else {
+			if last == nil || result.Render() != last.Render() {
+				for _, transition := range statusTransitions(last, result) {
+					transitions = append(transitions, time.Now().Format("15:04:05")+" "+transition)
+				}
+				if len(transitions) > maxTransitions {
+					transitions = transitions[len(transitions)-maxTransitions:]
+				}
+				if area != nil {
+					area.Update(result.render(last) + renderTransitions(transitions))
+				}
+				last = result
+			}
+
+			if statusUntilFlag != "" && result.Reached(statusUntilFlag) {
+				if structuredOutput() {
+					return printOutput(result)
+				}
+				_ = area.Stop()
+				pterm.Success.Printf("%s is %s\n", tenant, statusUntilFlag)
+				return nil
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-resync.C:
+		case err := <-errs:
+			return err
+		case <-timeout:
+			if structuredOutput() && last != nil {
+				_ = printOutput(last)
+			}
+			if statusUntilFlag != "" {
+				return errors.Errorf("timed out after %s waiting for %s to be %s", statusTimeoutFlag, tenant, statusUntilFlag)
+			}
+			return nil
+		}
+	}
+}
+
+// statusWatchLists are the kinds of objects a status is made from.
+func statusWatchLists(statusType string) []client.ObjectList {
+	if statusType != "Summon Platform" {
+		return []client.ObjectList{&dbv1beta2.PostgresDumpList{}}
+	}
+	return []client.ObjectList{&summonv1beta2.SummonPlatformList{}, &appsv1.DeploymentList{}, &corev1.PodList{}}
+}
+
+// watchObjects signals changed on every event for a kind of object in the
+// namespace, until the context is done. It only sends an error if a watch
+// can't be started.
+func watchObjects(ctx context.Context, c client.WithWatch, list client.ObjectList, namespace string, changed chan<- struct{}, errs chan<- error) {
+	for {
+		w, err := c.Watch(ctx, list, client.InNamespace(namespace))
+		if err != nil {
+			if ctx.Err() == nil {
+				select {
+				case errs <- errors.Wrapf(err, "error watching %T", list):
+				default:
+				}
+			}
+			return
+		}
+		for range w.ResultChan() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+		w.Stop()
+
+		// The server ends watches after a while, start a new one.
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Reached is true if the status is the given one, ignoring case. That is the
+// SummonPlatform status once it is for the current spec, or the status of the
+// newest backup.
+func (r *statusResult) Reached(status string) bool {
+	if r.Summon != nil {
+		return r.Summon.current() && strings.EqualFold(r.Summon.Status, status)
+	}
+	var newest *backupStatus
+	for i, backup := range r.Backups {
+		if newest == nil || backup.Created.After(newest.Created) {
+			newest = &r.Backups[i]
+		}
+	}
+	return newest != nil && strings.EqualFold(newest.Status, status)
+}
+
+// current is true if the status is for the current spec, so that e.g. the
+// Ready of the previous version isn't taken for the new one's right after it
+// is applied. That is when the operator observed the current generation, or
+// without observedGeneration, when it reports the desired summon version.
+func (s *summonStatus) current() bool {
+	if s.ObservedGeneration > 0 {
+		return s.ObservedGeneration >= s.Generation
+	}
+	version, ok := s.CurrentVersions[summonVersionKey]
+	return ok && version == s.DesiredVersions["summon"]
+}
+
+// statusTransitions describes what changed from the previous status.
+func statusTransitions(prev *statusResult, cur *statusResult) []string {
+	transitions := []string{}
+	if prev == nil {
+		return transitions
+	}
+	if prev.Summon != nil && cur.Summon != nil && prev.Summon.Status != cur.Summon.Status {
+		transitions = append(transitions, fmt.Sprintf("%s: %s → %s", cur.Tenant, prev.Summon.Status, cur.Summon.Status))
+	}
+
+	prevDeployments := map[string]deploymentStatus{}
+	for _, deployment := range prev.Deployments {
+		prevDeployments[deployment.Name] = deployment
+	}
+	for _, deployment := range cur.Deployments {
+		old, ok := prevDeployments[deployment.Name]
+		delete(prevDeployments, deployment.Name)
+		switch {
+		case !ok:
+			transitions = append(transitions, fmt.Sprintf("%s: created", deployment.Name))
+		case old.Version != deployment.Version:
+			transitions = append(transitions, fmt.Sprintf("%s: version %s → %s", deployment.Name, old.Version, deployment.Version))
+		case old.Ready != deployment.Ready || old.Desired != deployment.Desired:
+			transitions = append(transitions, fmt.Sprintf("%s: %d/%d → %d/%d ready", deployment.Name, old.Ready, old.Desired, deployment.Ready, deployment.Desired))
+		}
+	}
+	deleted := []string{}
+	for name := range prevDeployments {
+		deleted = append(deleted, name)
+	}
+	sort.Strings(deleted)
+	for _, name := range deleted {
+		transitions = append(transitions, fmt.Sprintf("%s: deleted", name))
+	}
+
+	prevBackups := map[string]backupStatus{}
+	for _, backup := range prev.Backups {
+		prevBackups[backup.Name] = backup
+	}
+	for _, backup := range cur.Backups {
+		old, ok := prevBackups[backup.Name]
+		if !ok {
+			transitions = append(transitions, fmt.Sprintf("%s: created (%s)", backup.Name, backup.Status))
+		} else if old.Status != backup.Status {
+			transitions = append(transitions, fmt.Sprintf("%s: %s → %s", backup.Name, old.Status, backup.Status))
+		}
+	}
+	return transitions
+}
+
+func renderTransitions(transitions []string) string {
+	if len(transitions) == 0 {
+		return ""
+	}
+	return "\nCHANGES:\n  " + strings.Join(transitions, "\n  ") + "\n"
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func summonResult(status string, generation int64, observedGeneration int64, desired string, current string) *statusResult {
+	return &statusResult{
+		Tenant: "darwin-qa",
+		Summon: &summonStatus{
+			Status:             status,
+			DesiredVersions:    map[string]string{"summon": desired},
+			CurrentVersions:    map[string]string{"summonVersion": current},
+			Generation:         generation,
+			ObservedGeneration: observedGeneration,
+		},
+	}
+}
+
+func TestStatusReached(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		result *statusResult
+		until  string
+		want   bool
+	}{
+		{"ready", summonResult("Ready", 2, 2, "1-abc", "1-abc"), "Ready", true},
+		{"ignores case", summonResult("Ready", 2, 2, "1-abc", "1-abc"), "ready", true},
+		{"other status", summonResult("Deploying", 2, 2, "1-abc", "1-abc"), "Ready", false},
+		{"previous generation", summonResult("Ready", 3, 2, "2-def", "1-abc"), "Ready", false},
+		{"newer observed generation", summonResult("Ready", 3, 4, "2-def", "2-def"), "Ready", true},
+		{"previous version", summonResult("Ready", 3, 0, "2-def", "1-abc"), "Ready", false},
+		{"current version", summonResult("Ready", 3, 0, "2-def", "2-def"), "Ready", true},
+		{
+			"other component version",
+			&statusResult{Summon: &summonStatus{
+				Status:          "Ready",
+				DesiredVersions: map[string]string{"summon": "2-def"},
+				CurrentVersions: map[string]string{"summonVersion": "1-abc", "dispatchVersion": "2-def"},
+				Generation:      3,
+			}},
+			"Ready",
+			false,
+		},
+		{"no backups", &statusResult{Backups: []backupStatus{}}, "Completed", false},
+		{
+			"newest backup",
+			&statusResult{Backups: []backupStatus{
+				{Name: "old", Status: "Failed", Created: now.Add(-time.Hour)},
+				{Name: "new", Status: "Completed", Created: now},
+			}},
+			"Completed",
+			true,
+		},
+		{
+			"older backup",
+			&statusResult{Backups: []backupStatus{
+				{Name: "old", Status: "Completed", Created: now.Add(-time.Hour)},
+				{Name: "new", Status: "Running", Created: now},
+			}},
+			"Completed",
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.result.Reached(test.until)
+			if got != test.want {
+				t.Errorf("Reached(%q) = %v, want %v", test.until, got, test.want)
+			}
+		})
+	}
+}
+
+func TestStatusTransitions(t *testing.T) {
+	withDeployments := func(result *statusResult, deployments ...deploymentStatus) *statusResult {
+		result.Deployments = deployments
+		return result
+	}
+	tests := []struct {
+		name string
+		prev *statusResult
+		cur  *statusResult
+		want []string
+	}{
+		{
+			name: "first status",
+			cur:  summonResult("Ready", 1, 1, "1-abc", "1-abc"),
+			want: []string{},
+		},
+		{
+			name: "unchanged",
+			prev: summonResult("Ready", 1, 1, "1-abc", "1-abc"),
+			cur:  summonResult("Ready", 1, 1, "1-abc", "1-abc"),
+			want: []string{},
+		},
+		{
+			name: "summon status",
+			prev: summonResult("Ready", 1, 1, "1-abc", "1-abc"),
+			cur:  summonResult("Deploying", 2, 2, "2-def", "1-abc"),
+			want: []string{"darwin-qa: Ready → Deploying"},
+		},
+		{
+			name: "deployments",
+			prev: withDeployments(summonResult("Ready", 1, 1, "1-abc", "1-abc"),
+				deploymentStatus{Name: "darwin-qa-celeryd", Ready: 1, Desired: 1, Version: "1-abc"},
+				deploymentStatus{Name: "darwin-qa-static", Ready: 1, Desired: 1, Version: "1-abc"},
+				deploymentStatus{Name: "darwin-qa-web", Ready: 2, Desired: 2, Version: "1-abc"},
+			),
+			cur: withDeployments(summonResult("Ready", 1, 1, "1-abc", "1-abc"),
+				deploymentStatus{Name: "darwin-qa-celerybeat", Ready: 0, Desired: 1, Version: "1-abc"},
+				deploymentStatus{Name: "darwin-qa-celeryd", Ready: 1, Desired: 1, Version: "2-def"},
+				deploymentStatus{Name: "darwin-qa-web", Ready: 1, Desired: 2, Version: "1-abc"},
+			),
+			want: []string{
+				"darwin-qa-celerybeat: created",
+				"darwin-qa-celeryd: version 1-abc → 2-def",
+				"darwin-qa-web: 2/2 → 1/2 ready",
+				"darwin-qa-static: deleted",
+			},
+		},
+		{
+			name: "backups",
+			prev: &statusResult{Backups: []backupStatus{
+				{Name: "darwin-qa-1", Status: "Completed"},
+				{Name: "darwin-qa-2", Status: "Running"},
+			}},
+			cur: &statusResult{Backups: []backupStatus{
+				{Name: "darwin-qa-1", Status: "Completed"},
+				{Name: "darwin-qa-2", Status: "Completed"},
+				{Name: "darwin-qa-3", Status: "Running"},
+			}},
+			want: []string{
+				"darwin-qa-2: Running → Completed",
+				"darwin-qa-3: created (Running)",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := statusTransitions(test.prev, test.cur)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("want:\n%q\ngot:\n%q", test.want, got)
+			}
+		})
+	}
+}
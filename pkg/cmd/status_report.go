@@ -0,0 +1,308 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ridecell/ridectl/pkg/kubernetes"
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1beta2 "github.com/Ridecell/ridecell-controllers/apis/db/v1beta2"
+	summonv1beta2 "github.com/Ridecell/summon-operator/apis/app/v1beta2"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// Component versions in the SummonPlatform spec, by their status names.
+var summonComponents = []struct {
+	name    string
+	label   string
+	version func(spec summonv1beta2.SummonPlatformSpec) string
+}{
+	{"summon", "Summon", func(spec summonv1beta2.SummonPlatformSpec) string { return spec.Version }},
+	{"hwAux", "HwAux", func(spec summonv1beta2.SummonPlatformSpec) string { return spec.HwAux.Version }},
+	{"dispatch", "Dispatch", func(spec summonv1beta2.SummonPlatformSpec) string { return spec.Dispatch.Version }},
+	{"businessPortal", "Business Portal", func(spec summonv1beta2.SummonPlatformSpec) string { return spec.BusinessPortal.Version }},
+	{"pulse", "Pulse", func(spec summonv1beta2.SummonPlatformSpec) string { return spec.Pulse.Version }},
+	{"tripShare", "TripShare", func(spec summonv1beta2.SummonPlatformSpec) string { return spec.TripShare.Version }},
+}
+
+// The current versions key of the summon version in the notification status.
+const summonVersionKey = "summonVersion"
+
+// statusResult is the output of status. Summon and Deployments are set for
+// the Summon Platform status, Backups for the DB Backup status.
+type statusResult struct {
+	Tenant      string             `json:"tenant"`
+	Namespace   string             `json:"namespace"`
+	Context     string             `json:"context"`
+	Summon      *summonStatus      `json:"summon,omitempty"`
+	Deployments []deploymentStatus `json:"deployments,omitempty"`
+	Backups     []backupStatus     `json:"backups,omitempty"`
+}
+
+type summonStatus struct {
+	Status          string            `json:"status"`
+	Message         string            `json:"message"`
+	DesiredVersions map[string]string `json:"desiredVersions"`
+	CurrentVersions map[string]string `json:"currentVersions"`
+	Slack           map[string]string `json:"slack,omitempty"`
+	// Generation is the generation of the spec, ObservedGeneration the one
+	// the status is for, 0 if the operator doesn't set it.
+	Generation         int64 `json:"generation"`
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+type deploymentStatus struct {
+	Name     string `json:"name"`
+	Ready    int32  `json:"ready"`
+	Desired  int32  `json:"desired"`
+	UpToDate int32  `json:"upToDate"`
+	Version  string `json:"version"`
+}
+
+type backupStatus struct {
+	Name    string    `json:"name"`
+	Status  string    `json:"status"`
+	Message string    `json:"message"`
+	Created time.Time `json:"created"`
+}
+
+// Names are the deployments or backups.
+func (r *statusResult) Names() []string {
+	names := []string{}
+	for _, deployment := range r.Deployments {
+		names = append(names, deployment.Name)
+	}
+	for _, backup := range r.Backups {
+		names = append(names, backup.Name)
+	}
+	return names
+}
+
+func (r *statusResult) PrintTable() {
+	pterm.Success.Printf("%s", r.Render())
+}
+
+// Render formats the status as text.
+func (r *statusResult) Render() string {
+	return r.render(nil)
+}
+
+// render formats the status as text, highlighting what changed from the
+// previous status if there is one.
+func (r *statusResult) render(prev *statusResult) string {
+	highlight := func(changed bool, text string) string {
+		if prev != nil && changed {
+			return pterm.Yellow(text)
+		}
+		return text
+	}
+
+	b := &strings.Builder{}
+	if r.Summon != nil {
+		fmt.Fprintf(b, "TENANT: %s\n", r.Tenant)
+		prevSummon := &summonStatus{}
+		if prev != nil && prev.Summon != nil {
+			prevSummon = prev.Summon
+		}
+		state := fmt.Sprintf("%s (%s)", r.Summon.Status, r.Summon.Message)
+		if prev != nil && prevSummon.Status != r.Summon.Status {
+			state = prevSummon.Status + " → " + state
+		}
+		fmt.Fprintf(b, "STATE: %s\n\n", highlight(prevSummon.Status != r.Summon.Status || prevSummon.Message != r.Summon.Message, state))
+		fmt.Fprintf(b, "DESIRED VERSIONS:\n")
+		for _, component := range summonComponents {
+			version, ok := r.Summon.DesiredVersions[component.name]
+			if ok || component.name == "summon" {
+				fmt.Fprintf(b, "%s\n", highlight(prevSummon.DesiredVersions[component.name] != version, fmt.Sprintf("  %s: %s", component.label, version)))
+			}
+		}
+		fmt.Fprintf(b, "\nCURRENT VERSIONS:\n")
+		for _, key := range sortedKeys(r.Summon.CurrentVersions) {
+			fmt.Fprintf(b, "%s\n", highlight(prevSummon.CurrentVersions[key] != r.Summon.CurrentVersions[key], fmt.Sprintf("  %s: %s", key, r.Summon.CurrentVersions[key])))
+		}
+		fmt.Fprintf(b, "  Slack:\n")
+		for _, key := range sortedKeys(r.Summon.Slack) {
+			fmt.Fprintf(b, "    %s: %s\n", key, r.Summon.Slack[key])
+		}
+
+		prevDeployments := map[string]deploymentStatus{}
+		if prev != nil {
+			for _, deployment := range prev.Deployments {
+				prevDeployments[deployment.Name] = deployment
+			}
+		}
+		fmt.Fprintf(b, "\n%-40s%-15s%-15s%s\n", "DEPLOYMENT", "READY/DESIRED", "UP-TO-DATE", "VERSION")
+		for _, deployment := range r.Deployments {
+			old, ok := prevDeployments[deployment.Name]
+			ready := fmt.Sprintf("%2s/%-13s", replicas(deployment.Ready), replicas(deployment.Desired))
+			if deployment.Desired == 0 {
+				ready = fmt.Sprintf("%-15s", "Scaled down")
+			}
+			row := fmt.Sprintf("%-40s%s%-15s%s", deployment.Name, ready, replicas(deployment.UpToDate), deployment.Version)
+			fmt.Fprintf(b, "%s\n", highlight(!ok || old != deployment, row))
+		}
+	}
+	if r.Backups != nil {
+		prevBackups := map[string]backupStatus{}
+		if prev != nil {
+			for _, backup := range prev.Backups {
+				prevBackups[backup.Name] = backup
+			}
+		}
+		fmt.Fprintf(b, "%-70s%-15s%s\n", "NAME", "STATUS", "MESSAGE")
+		for _, backup := range r.Backups {
+			old, ok := prevBackups[backup.Name]
+			row := fmt.Sprintf("%-70s%-15s%s", backup.Name, backup.Status, backup.Message)
+			fmt.Fprintf(b, "%s\n", highlight(!ok || old.Status != backup.Status || old.Message != backup.Message, row))
+		}
+	}
+	return b.String()
+}
+
+// replicas formats a replica count, with - for none as kubectl shows unset
+// counts.
+func replicas(count int32) string {
+	if count == 0 {
+		return "-"
+	}
+	return strconv.Itoa(int(count))
+}
+
+// getStatusResult reads the status of a tenant with the cluster client.
+func getStatusResult(ctx context.Context, kubeObj kubernetes.Kubeobject, namespace string, tenant string, statusType string) (*statusResult, error) {
+	result := &statusResult{Tenant: tenant, Namespace: namespace, Context: kubeObj.Context}
+	if statusType != "Summon Platform" {
+		backups, err := getBackupStatus(ctx, kubeObj.Client, namespace, tenant)
+		if err != nil {
+			return nil, err
+		}
+		result.Backups = backups
+		return result, nil
+	}
+
+	summon, err := getSummonStatus(ctx, kubeObj.Client, namespace, tenant)
+	if err != nil {
+		return nil, err
+	}
+	result.Summon = summon
+
+	deployments := &appsv1.DeploymentList{}
+	err = kubeObj.Client.List(ctx, deployments, client.InNamespace(namespace), client.MatchingLabels{"app.kubernetes.io/part-of": tenant})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting deployment info")
+	}
+	sort.Slice(deployments.Items, func(i, j int) bool {
+		return deployments.Items[i].Name < deployments.Items[j].Name
+	})
+	result.Deployments = []deploymentStatus{}
+	for _, deployment := range deployments.Items {
+		// Desired replicas default to 1 like in Kubernetes.
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		result.Deployments = append(result.Deployments, deploymentStatus{
+			Name:     deployment.Name,
+			Ready:    deployment.Status.ReadyReplicas,
+			Desired:  desired,
+			UpToDate: deployment.Status.UpdatedReplicas,
+			Version:  deployment.Labels["app.kubernetes.io/version"],
+		})
+	}
+	return result, nil
+}
+
+func getSummonStatus(ctx context.Context, c client.Client, namespace string, tenant string) (*summonStatus, error) {
+	summon := &summonv1beta2.SummonPlatform{}
+	err := c.Get(ctx, types.NamespacedName{Name: tenant, Namespace: namespace}, summon)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting summon platform info")
+	}
+
+	status := &summonStatus{
+		DesiredVersions: map[string]string{},
+		CurrentVersions: map[string]string{},
+	}
+	status.Status = summon.Status.Status
+	status.Message = summon.Status.Message
+	status.Generation = summon.Generation
+	fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&summon.Status)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading summon platform status")
+	}
+	status.ObservedGeneration, _, _ = unstructured.NestedInt64(fields, "observedGeneration")
+	for _, component := range summonComponents {
+		version := component.version(summon.Spec)
+		if version != "" {
+			status.DesiredVersions[component.name] = version
+		}
+	}
+	// The notification status is shown as it is, whatever components it has.
+	notification, _, _ := unstructured.NestedMap(fields, "notification")
+	for key, value := range notification {
+		switch key {
+		case "newRelic":
+		case "slack":
+			slack, _ := value.(map[string]interface{})
+			status.Slack = map[string]string{}
+			for k, v := range slack {
+				status.Slack[k] = fmt.Sprint(v)
+			}
+		default:
+			status.CurrentVersions[key] = fmt.Sprint(value)
+		}
+	}
+	return status, nil
+}
+
+func getBackupStatus(ctx context.Context, c client.Client, namespace string, tenant string) ([]backupStatus, error) {
+	dumps := &dbv1beta2.PostgresDumpList{}
+	err := c.List(ctx, dumps, client.InNamespace(namespace))
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting postgresdump instance info")
+	}
+	backups := []backupStatus{}
+	for _, dump := range dumps.Items {
+		// Microservice namespaces have the dumps of every service in them.
+		if strings.Contains(tenant, "svc-") && !strings.Contains(dump.GetName(), tenant) {
+			continue
+		}
+		backup := backupStatus{
+			Name:    dump.Name,
+			Status:  dump.Status.Status,
+			Message: dump.Status.Message,
+			Created: dump.CreationTimestamp.Time,
+		}
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Name < backups[j].Name
+	})
+	return backups, nil
+}
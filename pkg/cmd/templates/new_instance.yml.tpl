@@ -0,0 +1,21 @@
+apiVersion: app.summon.ridecell.io/v1beta2
+kind: SummonPlatform
+metadata:
+  name: {{ .Name }}
+  namespace: {{ .Namespace }}
+spec:
+  version: ""
+{{- if .SlackChannel }}
+  notifications:
+    slackChannel: {{ toJson .SlackChannel }}
+{{- end }}
+---
+apiVersion: secrets.controllers.ridecell.io/v1beta2
+kind: DecryptedSecret
+metadata:
+  name: {{ .Name }}
+  namespace: {{ .Namespace }}
+data:
+{{- range .Secrets }}
+  {{ .Key }}: {{ toJson .Value }}
+{{- end }}
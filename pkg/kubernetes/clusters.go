@@ -0,0 +1,186 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"gopkg.in/ini.v1"
+)
+
+/*
+
+Which kubeconfig contexts are searched for an instance is set in the
+[clusters] section of ~/.ridectl/ridectl.cfg, and one [clusters.<name>]
+section per kind of cluster:
+
+  [clusters]
+  hosts = teleport
+  teleport_prefix = teleport.aws-us-support.ridecell.io-
+
+  [clusters.eu-prod]
+  match = eu-prod-eks
+  envs = prod, uat
+  region = eu
+
+  [clusters.prod]
+  match = prod.kops
+  envs = prod, uat
+
+hosts are the patterns the API server host must contain, empty for any host.
+teleport_prefix is trimmed from context names to get the cluster name, e.g.
+for RDS instance names in dbshell.
+
+A context is classified by the first section with a match pattern contained
+in its name. It is only searched for instances of the envs of that section,
+and of its region if it has one. Contexts matching no section are searched
+for every env no section lists, so once an env is listed every cluster with
+it needs a section. Without any [clusters.<name>] sections, the default is
+the prod section above: prod and uat instances are in prod.kops clusters and
+all others are elsewhere.
+
+*/
+
+// ClusterRule classifies the contexts whose names contain a match pattern.
+type ClusterRule struct {
+	Name   string
+	Match  []string
+	Envs   []string
+	Region string
+}
+
+// ClusterConfig is the [clusters] configuration of ridectl.cfg.
+type ClusterConfig struct {
+	Hosts            []string
+	TeleportPrefixes []string
+	Rules            []ClusterRule
+}
+
+// Clusters is the cluster configuration in use.
+var Clusters = DefaultClusterConfig()
+
+// DefaultClusterConfig is the configuration without a ridectl.cfg.
+func DefaultClusterConfig() ClusterConfig {
+	return ClusterConfig{
+		Hosts:            []string{"teleport"},
+		TeleportPrefixes: []string{"teleport.aws-us-support.ridecell.io-"},
+		Rules: []ClusterRule{
+			{Name: "prod", Match: []string{"prod.kops"}, Envs: []string{"prod", "uat"}},
+		},
+	}
+}
+
+// LoadClusterConfig reads the cluster configuration from ridectl.cfg. Keys
+// and sections which are not set keep their defaults.
+func LoadClusterConfig(ridectlConfigFile string) ClusterConfig {
+	config := DefaultClusterConfig()
+	cfg, err := ini.LooseLoad(ridectlConfigFile)
+	if err != nil {
+		pterm.Warning.Printf("error reading %s: %v\n", ridectlConfigFile, err)
+		return config
+	}
+	section := cfg.Section("clusters")
+	if section.HasKey("hosts") {
+		config.Hosts = section.Key("hosts").Strings(",")
+	}
+	if section.HasKey("teleport_prefix") {
+		config.TeleportPrefixes = section.Key("teleport_prefix").Strings(",")
+	}
+
+	rules := []ClusterRule{}
+	for _, child := range section.ChildSections() {
+		rule := ClusterRule{
+			Name:   strings.TrimPrefix(child.Name(), "clusters."),
+			Match:  child.Key("match").Strings(","),
+			Envs:   child.Key("envs").Strings(","),
+			Region: child.Key("region").String(),
+		}
+		if len(rule.Match) == 0 || len(rule.Envs) == 0 {
+			pterm.Warning.Printf("ignoring [%s] in %s, it needs match and envs\n", child.Name(), ridectlConfigFile)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) > 0 {
+		config.Rules = rules
+	}
+	return config
+}
+
+// rule is the first rule matching a context, nil if there is none.
+func (c ClusterConfig) rule(clusterName string) *ClusterRule {
+	for i, rule := range c.Rules {
+		for _, pattern := range rule.Match {
+			if strings.Contains(clusterName, pattern) {
+				return &c.Rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+// ValidCluster is true if instances of the env and region can be in a
+// context. An empty region matches any.
+func (c ClusterConfig) ValidCluster(clusterName string, env string, region string) bool {
+	rule := c.rule(clusterName)
+	if rule == nil {
+		for _, rule := range c.Rules {
+			if slices.Contains(rule.Envs, env) {
+				return false
+			}
+		}
+		return true
+	}
+	if region != "" && rule.Region != "" && region != rule.Region {
+		return false
+	}
+	return slices.Contains(rule.Envs, env)
+}
+
+// Region is the region of a context, empty if its rule has none.
+func (c ClusterConfig) Region(clusterName string) string {
+	rule := c.rule(clusterName)
+	if rule == nil {
+		return ""
+	}
+	return rule.Region
+}
+
+// AllowedHost is true if an API server host can be searched for instances.
+func (c ClusterConfig) AllowedHost(host string) bool {
+	if len(c.Hosts) == 0 {
+		return true
+	}
+	for _, pattern := range c.Hosts {
+		if strings.Contains(host, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClusterName is a context name without its teleport proxy prefix.
+func (c ClusterConfig) ClusterName(kubeContext string) string {
+	for _, prefix := range c.TeleportPrefixes {
+		if strings.HasPrefix(kubeContext, prefix) {
+			return strings.TrimPrefix(kubeContext, prefix)
+		}
+	}
+	return kubeContext
+}
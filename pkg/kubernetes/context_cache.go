@@ -0,0 +1,133 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+/*
+
+Finding which cluster an instance is in means building a client for every
+kubeconfig context and asking each of them. The context an instance was last
+found in is cached in ContextCacheFile, so later commands only contact that
+one cluster. Entries expire after ContextCacheTTL, and are removed when the
+instance is no longer found in the cached context, which then falls back to
+searching every cluster.
+
+*/
+
+// ContextCacheFile is where found contexts are cached, empty to disable.
+var ContextCacheFile string
+
+// ContextCacheTTL is how long a cached context is used for.
+var ContextCacheTTL = 24 * time.Hour
+
+type contextCacheEntry struct {
+	Context string    `json:"context"`
+	Time    time.Time `json:"time"`
+}
+
+type contextCache struct {
+	Entries map[string]contextCacheEntry `json:"entries"`
+}
+
+// contextCacheKey identifies a subject in the cache.
+func contextCacheKey(subject Subject) string {
+	return fmt.Sprintf("%s/%s/%s", subject.Type, subject.Namespace, subject.Name)
+}
+
+// loadContextCache reads the cache, which is empty if it is missing or
+// invalid.
+func loadContextCache() *contextCache {
+	cache := &contextCache{Entries: map[string]contextCacheEntry{}}
+	if ContextCacheFile == "" || ContextCacheTTL <= 0 {
+		return cache
+	}
+	data, err := os.ReadFile(ContextCacheFile)
+	if err != nil {
+		return cache
+	}
+	err = json.Unmarshal(data, cache)
+	if err != nil || cache.Entries == nil {
+		return &contextCache{Entries: map[string]contextCacheEntry{}}
+	}
+	return cache
+}
+
+// get returns the cached context for a key, if it has not expired.
+func (c *contextCache) get(key string) (string, bool) {
+	entry, ok := c.Entries[key]
+	if !ok || time.Since(entry.Time) > ContextCacheTTL {
+		return "", false
+	}
+	return entry.Context, true
+}
+
+// put caches the context of a key and saves the cache.
+func (c *contextCache) put(key string, context string) {
+	c.Entries[key] = contextCacheEntry{Context: context, Time: time.Now()}
+	c.save()
+}
+
+// remove removes a key and saves the cache.
+func (c *contextCache) remove(key string) {
+	delete(c.Entries, key)
+	c.save()
+}
+
+// save writes the cache, dropping expired entries. It is written to a temp
+// file first so concurrent commands never read a partial file. Failing to
+// save only prints a warning.
+func (c *contextCache) save() {
+	if ContextCacheFile == "" || ContextCacheTTL <= 0 {
+		return
+	}
+	for key, entry := range c.Entries {
+		if time.Since(entry.Time) > ContextCacheTTL {
+			delete(c.Entries, key)
+		}
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		pterm.Warning.Printf("error encoding context cache: %v\n", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(ContextCacheFile), ".contexts-*.json")
+	if err != nil {
+		pterm.Warning.Printf("error writing context cache: %v\n", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), ContextCacheFile)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		pterm.Warning.Printf("error writing context cache: %v\n", err)
+	}
+}
@@ -18,12 +18,14 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/pterm/pterm"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -36,6 +38,8 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clientset "k8s.io/client-go/kubernetes"
 )
 
 const namespacePrefix = "summon-"
@@ -55,6 +59,29 @@ type Subject struct {
 }
 
 func getClientByContext(kubeconfig string, kubeContext *api.Context) (client.Client, error) {
+	cfg, err := getConfigByContext(kubeconfig, kubeContext)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := rest.HTTPClientFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := apiutil.NewDynamicRESTMapper(cfg, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// A client.WithWatch, so status --follow can watch objects.
+	client, err := client.NewWithWatch(cfg, client.Options{Scheme: scheme.Scheme, Mapper: mapper})
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func getConfigByContext(kubeconfig string, kubeContext *api.Context) (*rest.Config, error) {
 
 	var cfg *rest.Config
 	var err error
@@ -81,27 +108,55 @@ func getClientByContext(kubeconfig string, kubeContext *api.Context) (client.Cli
 		checkTSH := os.Getenv("RIDECTL_TSH_CHECK")
 
 		// Return error to skip searching non-ridecell hosts
-		if checkTSH != "false" && !strings.Contains(cfg.Host, "teleport") {
+		if checkTSH != "false" && !Clusters.AllowedHost(cfg.Host) {
 			return nil, errors.New("hostname did not match, ignoring context")
 		}
 	}
 	// Set high timeout, since user has to login if their teleport login is expired.
 	cfg.Timeout = time.Minute * 3
-	httpClient, err := rest.HTTPClientFor(cfg)
-	if err != nil {
-		return nil, err
+	return cfg, nil
+}
+
+// NewClientset returns a client-go clientset for the context an object was
+// found in, for what the controller-runtime client can't do such as streaming
+// logs. It has no timeout, so streams can be followed.
+func NewClientset(kubeconfig string, clusterName string, inCluster bool) (clientset.Interface, error) {
+	var kubeContext *api.Context
+	if inCluster {
+		kubeconfig = ""
+	} else {
+		contexts, err := getKubeContexts()
+		if err != nil {
+			return nil, errors.Wrap(err, ": Error getting kubecontexts")
+		}
+		var ok bool
+		kubeContext, ok = contexts[clusterName]
+		if !ok {
+			return nil, errors.Errorf("context %s is not in the kubeconfig", clusterName)
+		}
 	}
-	mapper, err := apiutil.NewDynamicRESTMapper(cfg, httpClient)
+	cfg, err := getConfigByContext(kubeconfig, kubeContext)
 	if err != nil {
 		return nil, err
 	}
+	cfg.Timeout = 0
+	return clientset.NewForConfig(cfg)
+}
 
-	client, err := client.New(cfg, client.Options{Scheme: scheme.Scheme, Mapper: mapper})
-	if err != nil {
-		return nil, err
+// PodLabels are the labels of the pods of a component of a subject, e.g. web.
+func PodLabels(subject Subject, component string) labels.Set {
+	switch subject.Type {
+	case "summon":
+		return labels.Set{"app.kubernetes.io/instance": fmt.Sprintf("%s-%s", subject.Name, component)}
+	case "microservice":
+		return labels.Set{
+			"app":         fmt.Sprintf("%s-svc-%s", subject.Env, subject.Namespace),
+			"environment": subject.Env,
+			"region":      subject.Region,
+			"role":        component,
+		}
 	}
-
-	return client, nil
+	return labels.Set{}
 }
 
 func getKubeContexts() (map[string]*api.Context, error) {
@@ -116,10 +171,20 @@ func getKubeContexts() (map[string]*api.Context, error) {
 	return rawConfig.Contexts, nil
 }
 
-func fetchContextForObject(channel chan Kubeobject, clusterName string, cluster *api.Context, crclient client.Client, subject Subject, wg *sync.WaitGroup) {
+// PinnedContext is the kubeconfig context to use instead of searching every
+// cluster, empty to search.
+var PinnedContext string
 
-	defer wg.Done()
+// SelectContext picks which of the contexts a subject was found in to use.
+// If it is not set, finding a subject in more than one context is an error.
+var SelectContext func(subject Subject, contexts []string) (string, error)
 
+// errObjectNotFound is returned when a cluster does not have the subject.
+var errObjectNotFound = errors.New("object not found")
+
+// findObject looks for the subject in a cluster. It returns
+// errObjectNotFound if the cluster does not have it.
+func findObject(clusterName string, kubeContext *api.Context, crclient client.Client, subject Subject) (Kubeobject, error) {
 	var objectName string
 	switch subject.Type {
 	case "summon":
@@ -128,13 +193,9 @@ func fetchContextForObject(channel chan Kubeobject, clusterName string, cluster
 		err := crclient.Get(context.TODO(), types.NamespacedName{Name: subject.Name, Namespace: subject.Namespace}, summonObj)
 		if err != nil {
 			pterm.Warning.Printf("%s in %s\n", err.Error(), clusterName)
-			return
-		}
-
-		if err == nil {
-			channel <- Kubeobject{Object: summonObj, Context: clusterName, Client: crclient}
-			return
+			return Kubeobject{}, notFoundOr(err)
 		}
+		return Kubeobject{Object: summonObj, Context: clusterName, Client: crclient}, nil
 
 	case "microservice":
 		objectName = fmt.Sprintf("%s-svc-%s-web", subject.Env, subject.Namespace)
@@ -144,27 +205,35 @@ func fetchContextForObject(channel chan Kubeobject, clusterName string, cluster
 		err := crclient.Get(context.Background(), types.NamespacedName{Name: objectName, Namespace: subject.Namespace}, deploymentObj)
 		if err != nil {
 			pterm.Warning.Printf("%s in %s\n", err.Error(), clusterName)
-			return
+			return Kubeobject{}, notFoundOr(err)
 		}
 		// This makes sure we are returning the correct context.
 		// In the case of microservices, the deployment name is same for all clusters
-		if err == nil && deploymentObj.Labels["region"] == subject.Region {
-			channel <- Kubeobject{Client: crclient, Context: clusterName}
-		} else {
-			return
+		if deploymentObj.Labels["region"] != subject.Region {
+			return Kubeobject{}, errObjectNotFound
 		}
+		return Kubeobject{Client: crclient, Context: clusterName}, nil
+
 	case "job":
 		jobObj := &batchv1.Job{}
 
-		pterm.Info.Printf(" Checking job in %s\n", cluster.Cluster)
+		pterm.Info.Printf(" Checking job in %s\n", kubeContext.Cluster)
 		err := crclient.Get(context.Background(), types.NamespacedName{Name: subject.Name, Namespace: subject.Namespace}, jobObj)
 		if err != nil {
-			pterm.Warning.Printf("%s in %s\n", err.Error(), cluster.Cluster)
-			return
+			pterm.Warning.Printf("%s in %s\n", err.Error(), kubeContext.Cluster)
+			return Kubeobject{}, notFoundOr(err)
 		}
-		channel <- Kubeobject{Object: jobObj, Client: crclient, Context: clusterName}
-		return
+		return Kubeobject{Object: jobObj, Client: crclient, Context: clusterName}, nil
+	}
+	return Kubeobject{}, errors.Errorf("unknown subject type %s", subject.Type)
+}
+
+// notFoundOr turns a NotFound API error into errObjectNotFound.
+func notFoundOr(err error) error {
+	if apierrors.IsNotFound(err) {
+		return errObjectNotFound
 	}
+	return err
 }
 
 func GetAppropriateObjectWithContext(kubeconfig string, instance string, subject Subject, inCluster bool) (Kubeobject, error) {
@@ -186,38 +255,119 @@ func GetAppropriateObjectWithContext(kubeconfig string, instance string, subject
 		return Kubeobject{}, errors.Wrap(err, ": Error getting kubecontexts")
 	}
 
-	k8sClients := make(map[string]client.Client)
-	for clusterName, context := range contexts {
-		if !validCluster(clusterName, subject.Env) {
-			continue
+	if PinnedContext != "" {
+		return findObjectInContext(kubeconfig, contexts, PinnedContext, subject)
+	}
+
+	// Fast path, only contact the context the subject was last found in.
+	cache := loadContextCache()
+	cacheKey := contextCacheKey(subject)
+	if clusterName, ok := cache.get(cacheKey); ok {
+		if kubeContext, ok := contexts[clusterName]; ok && validCluster(clusterName, subject.Env, subject.Region) {
+			crclient, err := getClientByContext(kubeconfig, kubeContext)
+			if err == nil {
+				var kubeObj Kubeobject
+				kubeObj, err = findObject(clusterName, kubeContext, crclient, subject)
+				if err == nil {
+					cache.put(cacheKey, clusterName)
+					return kubeObj, nil
+				}
+			}
+			if err == errObjectNotFound {
+				cache.remove(cacheKey)
+			}
 		}
-		k8sClient, err := getClientByContext(kubeconfig, context)
-		if err != nil {
+		pterm.Info.Printf("%s was not found in the cached context %s, searching all clusters\n", subject.Name, clusterName)
+	}
+
+	// Clients are only built for the valid clusters, concurrently.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	found := []Kubeobject{}
+	clientCount := 0
+	for clusterName, kubeContext := range contexts {
+		if !validCluster(clusterName, subject.Env, subject.Region) {
 			continue
 		}
-		k8sClients[clusterName] = k8sClient
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			crclient, err := getClientByContext(kubeconfig, kubeContext)
+			if err != nil {
+				return
+			}
+			kubeObj, err := findObject(clusterName, kubeContext, crclient, subject)
+			mu.Lock()
+			defer mu.Unlock()
+			clientCount++
+			if err == nil {
+				found = append(found, kubeObj)
+			}
+		}()
 	}
+	// Block until all of my goroutines have processed their issues.
+	wg.Wait()
 
-	if len(k8sClients) < 1 {
+	if clientCount < 1 {
 		return Kubeobject{}, errors.New("No valid cluster was found")
 	}
+	if len(found) < 1 {
+		return Kubeobject{}, nil
+	}
+	kubeObj, err := selectFound(subject, found)
+	if err != nil {
+		return Kubeobject{}, err
+	}
+	cache.put(cacheKey, kubeObj.Context)
+	return kubeObj, nil
+}
 
-	// Initialize a wait group
-	var wg sync.WaitGroup
-	wg.Add(len(k8sClients))
-
-	objChannel := make(chan Kubeobject, len(k8sClients))
-	defer close(objChannel)
-
-	for cluster, client := range k8sClients {
-		go fetchContextForObject(objChannel, cluster, contexts[cluster], client, subject, &wg)
+// findObjectInContext looks for the subject in the given context only.
+func findObjectInContext(kubeconfig string, contexts map[string]*api.Context, clusterName string, subject Subject) (Kubeobject, error) {
+	kubeContext, ok := contexts[clusterName]
+	if !ok {
+		return Kubeobject{}, errors.Errorf("context %s is not in the kubeconfig", clusterName)
 	}
-	// Block until all of my goroutines have processed their issues.
-	wg.Wait()
-	if len(objChannel) < 1 {
+	if !validCluster(clusterName, subject.Env, subject.Region) {
+		pterm.Warning.Printf("context %s is not a cluster for %s instances, using it anyway\n", clusterName, subject.Env)
+	}
+	crclient, err := getClientByContext(kubeconfig, kubeContext)
+	if err != nil {
+		return Kubeobject{}, errors.Wrapf(err, "error getting client for context %s", clusterName)
+	}
+	kubeObj, err := findObject(clusterName, kubeContext, crclient, subject)
+	if err == errObjectNotFound {
 		return Kubeobject{}, nil
 	}
-	return <-objChannel, nil
+	return kubeObj, err
+}
+
+// selectFound picks which context to use when the subject was found in more
+// than one, instead of whichever answered first.
+func selectFound(subject Subject, found []Kubeobject) (Kubeobject, error) {
+	if len(found) == 1 {
+		return found[0], nil
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Context < found[j].Context
+	})
+	contexts := []string{}
+	for _, kubeObj := range found {
+		contexts = append(contexts, kubeObj.Context)
+	}
+	if SelectContext == nil {
+		return Kubeobject{}, errors.Errorf("%s was found in more than one context: %s, pass --context to pick one", subject.Name, strings.Join(contexts, ", "))
+	}
+	clusterName, err := SelectContext(subject, contexts)
+	if err != nil {
+		return Kubeobject{}, err
+	}
+	for _, kubeObj := range found {
+		if kubeObj.Context == clusterName {
+			return kubeObj, nil
+		}
+	}
+	return Kubeobject{}, errors.Errorf("%s was not found in context %s", subject.Name, clusterName)
 }
 
 // Parses the instance and returns an array of strings denoting: [region, env, subject, namespace]
@@ -253,11 +403,8 @@ func ParseSubject(instanceName string) (Subject, error) {
 }
 
 // Return true only if given Environment is present in target cluster
-func validCluster(clusterName string, env string) bool {
-	if env == "prod" || env == "uat" {
-		return strings.Contains(clusterName, "prod.kops")
-	}
-	return !strings.Contains(clusterName, "prod.kops")
+func validCluster(clusterName string, env string, region string) bool {
+	return Clusters.ValidCluster(clusterName, env, region)
 }
 
 // Returns Pod container's status if it is ready
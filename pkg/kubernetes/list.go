@@ -0,0 +1,223 @@
+/*
+Copyright 2026 Ridecell, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	summonv1beta2 "github.com/Ridecell/summon-operator/apis/app/v1beta2"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// Instance is a Summon instance or microservice found in a cluster.
+type Instance struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Env     string `json:"env"`
+	Region  string `json:"region,omitempty"`
+	Context string `json:"context"`
+	Version string `json:"version,omitempty"`
+	Status  string `json:"status,omitempty"`
+}
+
+// ListFilter limits the instances listed. Empty fields match everything.
+type ListFilter struct {
+	Env    string
+	Region string
+	Type   string
+}
+
+func (f ListFilter) matches(instance Instance) bool {
+	return (f.Env == "" || f.Env == instance.Env) &&
+		(f.Region == "" || f.Region == instance.Region) &&
+		(f.Type == "" || f.Type == instance.Type)
+}
+
+// ListInstances lists the Summon instances and microservices in every valid
+// cluster, or only PinnedContext, concurrently. Clusters which can't be listed are warned about and
+// skipped.
+func ListInstances(kubeconfig string, filter ListFilter, inCluster bool) ([]Instance, error) {
+	clients := map[string]func() (client.Client, error){}
+	if inCluster {
+		clients[""] = func() (client.Client, error) { return getClientByContext("", nil) }
+	} else {
+		contexts, err := getKubeContexts()
+		if err != nil {
+			return nil, errors.Wrap(err, ": Error getting kubecontexts")
+		}
+		if PinnedContext != "" {
+			kubeContext, ok := contexts[PinnedContext]
+			if !ok {
+				return nil, errors.Errorf("context %s is not in the kubeconfig", PinnedContext)
+			}
+			contexts = map[string]*api.Context{PinnedContext: kubeContext}
+		}
+		for clusterName, kubeContext := range contexts {
+			if PinnedContext == "" && filter.Env != "" && !validCluster(clusterName, filter.Env, filter.Region) {
+				continue
+			}
+			clients[clusterName] = func() (client.Client, error) { return getClientByContext(kubeconfig, kubeContext) }
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	instances := []Instance{}
+	clientCount := 0
+	for clusterName, newClient := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			crclient, err := newClient()
+			if err != nil {
+				pterm.Warning.Printf("%v in %s\n", err, clusterName)
+				return
+			}
+			found, errs := listInstancesInCluster(context.Background(), clusterName, crclient, filter)
+			mu.Lock()
+			defer mu.Unlock()
+			clientCount++
+			for _, err := range errs {
+				pterm.Warning.Printf("%s in %s\n", err.Error(), clusterName)
+			}
+			for _, instance := range found {
+				// Only list what the other commands would find in this cluster.
+				if inCluster || PinnedContext != "" || validCluster(clusterName, instance.Env, instance.Region) {
+					instances = append(instances, instance)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if clientCount < 1 {
+		return nil, errors.New("No valid cluster was found")
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].Name != instances[j].Name {
+			return instances[i].Name < instances[j].Name
+		}
+		return instances[i].Context < instances[j].Context
+	})
+	return instances, nil
+}
+
+// listInstancesInCluster lists the SummonPlatforms and microservice web
+// Deployments in a cluster matching the filter. Either can fail on its own,
+// e.g. in clusters without the SummonPlatform CRD.
+func listInstancesInCluster(ctx context.Context, clusterName string, crclient client.Client, filter ListFilter) ([]Instance, []error) {
+	instances := []Instance{}
+	errs := []error{}
+
+	if filter.Type == "" || filter.Type == "summon" {
+		summons := &summonv1beta2.SummonPlatformList{}
+		err := crclient.List(ctx, summons)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "error listing summon platforms"))
+		}
+		for _, summon := range summons.Items {
+			subject, err := ParseSubject(summon.Name)
+			if err != nil || subject.Type != "summon" {
+				continue
+			}
+			instance := Instance{
+				Name:    summon.Name,
+				Type:    "summon",
+				Env:     subject.Env,
+				Region:  summon.Labels["region"],
+				Context: clusterName,
+				Version: summon.Spec.Version,
+				Status:  summon.Status.Status,
+			}
+			if instance.Region == "" {
+				instance.Region = Clusters.Region(clusterName)
+			}
+			if filter.matches(instance) {
+				instances = append(instances, instance)
+			}
+		}
+	}
+
+	if filter.Type == "" || filter.Type == "microservice" {
+		deployments := &appsv1.DeploymentList{}
+		err := crclient.List(ctx, deployments, client.MatchingLabels{"role": "web"})
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "error listing microservice deployments"))
+		}
+		for _, deployment := range deployments.Items {
+			// Web deployments are named <env>-svc-<namespace>-web, see
+			// findObject.
+			env := deployment.Labels["environment"]
+			region := deployment.Labels["region"]
+			if env == "" || region == "" || deployment.Name != fmt.Sprintf("%s-svc-%s-web", env, deployment.Namespace) {
+				continue
+			}
+			instance := Instance{
+				Name:    fmt.Sprintf("svc-%s-%s-%s", region, env, deployment.Namespace),
+				Type:    "microservice",
+				Env:     env,
+				Region:  region,
+				Context: clusterName,
+				Version: imageTag(deployment),
+				Status:  deploymentStatus(deployment),
+			}
+			if filter.matches(instance) {
+				instances = append(instances, instance)
+			}
+		}
+	}
+	return instances, errs
+}
+
+// imageTag is the tag of the first container image of a deployment.
+func imageTag(deployment appsv1.Deployment) string {
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return ""
+	}
+	image := containers[0].Image
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	return image[i+1:]
+}
+
+// deploymentStatus compares the ready replicas of a deployment to the
+// desired ones, which default to 1 like in Kubernetes.
+func deploymentStatus(deployment appsv1.Deployment) string {
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	switch {
+	case desired == 0:
+		return "Scaled down"
+	case deployment.Status.ReadyReplicas >= desired:
+		return "Ready"
+	}
+	return fmt.Sprintf("NotReady (%d/%d)", deployment.Status.ReadyReplicas, desired)
+}